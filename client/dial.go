@@ -0,0 +1,35 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"os"
+)
+
+// dial connects to the server at addr. With useTLS the connection is
+// encrypted and the certificate of the server is checked against the system
+// roots, or the certificates in caFile if it is set. insecure skips the
+// check, which is only meant for testing.
+func dial(addr string, useTLS, insecure bool, caFile string) (net.Conn, error) {
+	if !useTLS {
+		return net.Dial("tcp", addr)
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{ServerName: host, InsecureSkipVerify: insecure, MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in " + caFile)
+		}
+	}
+	return tls.Dial("tcp", addr, config)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"landlord/server"
+)
+
+// serveTLS accepts tls connections with an in-memory certificate and
+// answers every connection with a line. It returns the address and the
+// certificate as PEM.
+func serveTLS(t *testing.T) (string, []byte) {
+	certPEM, keyPEM, err := server.SelfSignedCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listen, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listen.Close() })
+	go func() {
+		for {
+			conn, err := listen.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("ok\n"))
+			conn.Close()
+		}
+	}()
+	return listen.Addr().String(), certPEM
+}
+
+func TestDialTLS(t *testing.T) {
+	addr, certPEM := serveTLS(t)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, certPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+	read := func(conn net.Conn) string {
+		defer conn.Close()
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		return line
+	}
+
+	conn, err := dial(addr, true, false, caFile)
+	if err != nil {
+		t.Fatalf("expected the certificate to be trusted with --ca: %s", err)
+	}
+	if line := read(conn); line != "ok\n" {
+		t.Errorf("unexpected answer %q", line)
+	}
+	conn, err = dial(addr, true, true, "")
+	if err != nil {
+		t.Fatalf("expected --insecure to skip the check: %s", err)
+	}
+	read(conn)
+	if conn, err = dial(addr, true, false, ""); err == nil {
+		conn.Close()
+		t.Error("expected an untrusted certificate to be rejected")
+	}
+	if _, err = dial(addr, true, false, addr); err == nil {
+		t.Error("expected a missing ca file to be reported")
+	}
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"bufio"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
@@ -27,52 +29,60 @@ func main() {
 
 	log.SetOutput(f)
 	log.Println("______________________")
-	args := os.Args[1:]
-	var conn net.Conn
-
-	if len(args) == 0 {
-		conn, err = net.Dial("tcp", "45.77.149.81:8888")
-		// conn, err = net.Dial("tcp", "127.0.0.1:8888")
-	} else {
-		conn, err = net.Dial("tcp", args[0])
+	useTLS := flag.Bool("tls", false, "connect to the server with tls")
+	insecure := flag.Bool("insecure", false, "don't check the tls certificate of the server, implies --tls")
+	caFile := flag.String("ca", "", "trust the certificates in this file, e.g. a self-signed one, implies --tls")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [address]\n", os.Args[0])
+		flag.PrintDefaults()
 	}
+	flag.Parse()
 
+	addr := "45.77.149.81:8888"
+	// addr := "127.0.0.1:8888"
+	if flag.NArg() > 0 {
+		addr = flag.Arg(0)
+	}
+	conn, err := dial(addr, *useTLS || *insecure || *caFile != "", *insecure, *caFile)
 	if err != nil {
 		log.Fatalln(err)
 	}
 	defer conn.Close()
 	done := make(chan struct{})
-	setName(conn, done)
+	// the messages follow the handshake on the same reader
+	reader := bufio.NewReader(conn)
+	setName(conn, reader, done)
 	<-done
 	log.Println("Done")
 	go sendData(conn)
-	go listenData(conn)
-	time.Sleep(200 * time.Millisecond)
+	go listenData(reader)
 	Run(app)
 
 }
 
-func setName(conn net.Conn, done chan struct{}) {
+func setName(conn net.Conn, reader *bufio.Reader, done chan struct{}) {
 	finished := false
 	go func() {
-		reader := bufio.NewReader(os.Stdin)
+		stdin := bufio.NewReader(os.Stdin)
 		for {
 			if finished {
 				return
 			} else {
 
-				line, err := reader.ReadString('\n')
+				line, err := stdin.ReadString('\n')
 				if err != nil {
 					panic(err)
 				}
-				conn.Write([]byte(line + "\n"))
+				conn.Write([]byte(strings.TrimRight(line, "\r\n") + "\n"))
 			}
 			time.Sleep(1 * time.Second)
 		}
 	}()
 	go func() {
-		reader := bufio.NewReader(conn)
-		fmt.Print("Please type your nickname: ")
+		fmt.Println("Type a nickname to play as a guest, or")
+		fmt.Println("  /register <nickname> <password> to create an account")
+		fmt.Println("  /login <nickname> <password> to log in")
+		fmt.Print("> ")
 		for {
 			line, err := reader.ReadString('\n')
 			log.Println(line)
@@ -84,7 +94,7 @@ func setName(conn net.Conn, done chan struct{}) {
 				finished = true
 				break
 			} else {
-				fmt.Print("Please provide a valid nickname: ")
+				fmt.Printf("%s, please try again: ", strings.TrimSpace(line))
 			}
 		}
 		return
@@ -104,30 +114,15 @@ func sendData(conn net.Conn) {
 	}
 }
 
-func listenData(conn net.Conn) {
+// listenData decodes the messages of the server, which are JSON objects
+// one after another.
+func listenData(reader io.Reader) {
+	dec := json.NewDecoder(reader)
 	for {
-		buf := [10240]byte{}
-
-		n, err := conn.Read(buf[:])
-
-		if err != nil {
-			return
-		}
 		var msg message
-		dec := json.NewDecoder(strings.NewReader(string(buf[:n])))
-		for {
-			if err := dec.Decode(&msg); err != nil {
-				break
-			}
-			msgChan <- msg
+		if err := dec.Decode(&msg); err != nil {
+			return
 		}
-		// err = json.Unmarshal(buf[:n], &msg)
-		// if err != nil {
-		// log.Println(string(buf[:n]))
-		// panic(err)
-		// }
-		// log.Println(msg)
-		// msgChan <- msg
-		// fmt.Print(string(buf[:n]))
+		msgChan <- msg
 	}
 }
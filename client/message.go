@@ -10,6 +10,7 @@ const (
 	MSG_CHAT
 	MSG_ROOM_INFO
 	MSG_STOP
+	MSG_STATS
 )
 
 // type client struct {
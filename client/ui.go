@@ -7,6 +7,8 @@ import (
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+
+	"landlord/server/util"
 )
 
 var bgColor = tcell.ColorDefault
@@ -23,8 +25,8 @@ func setBoxAttr(box *tview.Box, title string) {
 	box.SetBorderColor(bgColor)
 }
 
-func drawSidebar() (*tview.Grid, *tview.TextView, *tview.TextView, *tview.TextView) {
-	sidebarGrid := tview.NewGrid().SetRows(-1, -1, -1).SetBorders(false)
+func drawSidebar() (*tview.Grid, *tview.TextView, *tview.TextView, *tview.TextView, *tview.TextView) {
+	sidebarGrid := tview.NewGrid().SetRows(-1, -1, -1, -1).SetBorders(false)
 	roomInfoView := tview.NewTextView().SetDynamicColors(true)
 	roomInfoView.SetBackgroundColor(bgColor)
 	roomInfoView.SetTextColor(bgColor)
@@ -40,12 +42,18 @@ func drawSidebar() (*tview.Grid, *tview.TextView, *tview.TextView, *tview.TextVi
 	infoView.SetTextColor(bgColor)
 	setBoxAttr(infoView.Box, "Info")
 
+	leaderboardView := tview.NewTextView().SetDynamicColors(false)
+	leaderboardView.SetBackgroundColor(bgColor)
+	leaderboardView.SetTextColor(bgColor)
+	setBoxAttr(leaderboardView.Box, "Leaderboard")
+
 	sidebarGrid.
 		AddItem(roomInfoView, 0, 0, 1, 1, 0, 0, false).
 		AddItem(chatView, 1, 0, 1, 1, 0, 0, false).
-		AddItem(infoView, 2, 0, 1, 1, 0, 0, false)
+		AddItem(infoView, 2, 0, 1, 1, 0, 0, false).
+		AddItem(leaderboardView, 3, 0, 1, 1, 0, 0, false)
 
-	return sidebarGrid, roomInfoView, chatView, infoView
+	return sidebarGrid, roomInfoView, chatView, infoView, leaderboardView
 }
 
 func drawMainPanel() (*tview.Grid, *tview.TextView, *tview.TextView, *tview.InputField) {
@@ -74,7 +82,7 @@ func drawMainPanel() (*tview.Grid, *tview.TextView, *tview.TextView, *tview.Inpu
 }
 
 func draw(app *tview.Application) *tview.Grid {
-	sidebarGrid, roomInfoView, chatView, infoView := drawSidebar()
+	sidebarGrid, roomInfoView, chatView, infoView, leaderboardView := drawSidebar()
 	mainPanelGrid, messagesView, statusView, input := drawMainPanel()
 	rootGrid := tview.NewGrid().SetColumns(-3, -5).SetBorders(false)
 	rootGrid.
@@ -84,6 +92,14 @@ func draw(app *tview.Application) *tview.Grid {
 	input.SetDoneFunc(func(key tcell.Key) {
 		if key == tcell.KeyEnter {
 			msg := strings.Trim(input.GetText(), "\r\n")
+			if cards, ok := strings.CutPrefix(msg, "/use "); ok {
+				// the server would refuse them as well
+				if _, err := util.ParseCards(cards); err != nil {
+					msgChan <- message{MsgType: MSG_MESSAGE, Content: "> " + err.Error()}
+					input.SetText("")
+					return
+				}
+			}
 			sendChan <- msg
 
 			historyIdx = len(history)
@@ -95,7 +111,7 @@ func draw(app *tview.Application) *tview.Grid {
 		if len(currentText) == 0 || currentText[0] != '/' {
 			return
 		}
-		cmds := []string{"/ready (ready for game)", "/use card1 card2.. (play selected cards) ", "/pass (pass current turn)", "/quit (quit the game)"}
+		cmds := []string{"/ready (ready for game)", "/use cards (play cards, e.g. 33344, 3 3 3 4 4 or s3 h3) ", "/pass (pass current turn)", "/bid points (bid 1-3 for becoming the landlord, 0 to pass)", "/queue [leave] (find a table with players of your level)", "/rooms (list the rooms)", "/join room|code (move to another room)", "/create name [--private [code]] (create a room)", "/room [kick|lock|unlock|owner|seats|timer|bidding] (show or change your room)", "/list (list the players in your room)", "/tournament [join|leave] (show or join the tournament)", "/match deals|to score|off (play a series of deals)", "/stats [nickname] (show statistics)", "/leaderboard (show the best players)", "/quit (quit the game)"}
 		for _, entry := range cmds {
 			if strings.HasPrefix(entry, currentText) {
 				entries = append(entries, entry)
@@ -118,7 +134,7 @@ func draw(app *tview.Application) *tview.Grid {
 		return source == tview.AutocompletedEnter || source == tview.AutocompletedClick
 	})
 
-	go handleMessages(app, messagesView, roomInfoView, statusView, chatView, infoView)
+	go handleMessages(app, messagesView, roomInfoView, statusView, chatView, infoView, leaderboardView)
 
 	return rootGrid
 }
@@ -137,6 +153,7 @@ func handleMessages(
 	statusView *tview.TextView,
 	chatView *tview.TextView,
 	infoView *tview.TextView,
+	leaderboardView *tview.TextView,
 ) {
 	for message := range msgChan {
 		switch message.MsgType {
@@ -171,6 +188,8 @@ func handleMessages(
 			roomInfoMsgs := strings.Split(message.Content, "_")
 			roomInfoStr := "Status: " + roomInfoMsgs[0] + "\nPlayers:\n" + roomInfoMsgs[1]
 			roomInfoView.SetText(roomInfoStr)
+		case MSG_STATS:
+			leaderboardView.SetText(message.Content)
 		case MSG_STOP:
 			history = append(history, message.Content)
 			log.Println(message.Content)
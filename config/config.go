@@ -0,0 +1,279 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	LEVEL_DEBUG = "debug"
+	LEVEL_INFO  = "info"
+)
+
+// Duration is a time.Duration that reads and writes as a string like "300s"
+// in config files and on the command line.
+type Duration time.Duration
+
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *Duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %s", string(b))
+	}
+	return d.Set(s)
+}
+
+type Rules struct {
+	NumPlayers int `json:"num_players"`
+	// TurnTimeout passes the turn of a player who doesn't act in time, or
+	// plays the lowest card if the player leads, zero waits forever.
+	TurnTimeout Duration `json:"turn_timeout"`
+	// Bidding lets the players bid for becoming the landlord, otherwise the
+	// landlord is picked at random
+	Bidding bool `json:"bidding"`
+}
+
+// Limits protect the server from clients flooding it. Zero disables a limit.
+type Limits struct {
+	ConnectionsPerIP int `json:"connections_per_ip"`
+	// CommandRate is the number of commands per second a client can send
+	// after a burst of CommandBurst commands, chat messages included
+	CommandRate  float64 `json:"command_rate"`
+	CommandBurst int     `json:"command_burst"`
+	// ChatRate is the number of chat messages per second after a burst of
+	// ChatBurst messages
+	ChatRate  float64 `json:"chat_rate"`
+	ChatBurst int     `json:"chat_burst"`
+}
+
+// TLS encrypts the connections of the game server. Without a certificate
+// and key a self-signed certificate is generated in the data directory,
+// which is only meant for local use.
+type TLS struct {
+	Enabled bool   `json:"enabled"`
+	Cert    string `json:"cert"`
+	Key     string `json:"key"`
+}
+
+type Config struct {
+	Listen      string   `json:"listen"`
+	TLS         TLS      `json:"tls"`
+	WebListen   string   `json:"web_listen"`
+	AdminListen string   `json:"admin_listen"`
+	AdminToken  string   `json:"admin_token"`
+	LogFile     string   `json:"log_file"`
+	LogLevel    string   `json:"log_level"`
+	IdleTimeout Duration `json:"idle_timeout"`
+	Rules       Rules    `json:"rules"`
+	Limits      Limits   `json:"limits"`
+	DataDir     string   `json:"data_dir"`
+	// ConsoleSocket is the path of a unix socket serving the operator
+	// console, empty to only read the console from stdin
+	ConsoleSocket string `json:"console_socket"`
+}
+
+func Default() Config {
+	return Config{
+		Listen:      "0.0.0.0:8888",
+		WebListen:   "0.0.0.0:8080",
+		AdminListen: "0.0.0.0:8889",
+		LogFile:     "server.log",
+		LogLevel:    LEVEL_INFO,
+		IdleTimeout: Duration(300 * time.Second),
+		Rules: Rules{
+			NumPlayers: 3,
+		},
+		Limits: Limits{
+			ConnectionsPerIP: 5,
+			CommandRate:      5,
+			CommandBurst:     10,
+			ChatRate:         0.5,
+			ChatBurst:        5,
+		},
+		DataDir: "data",
+	}
+}
+
+// Load reads a json config file. Settings missing from the file keep their
+// default values.
+func Load(path string) (Config, error) {
+	cfg := Default()
+	f, err := os.Open(path)
+	if err != nil {
+		return cfg, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Parse builds the config from the command line. Flags override the values
+// of the file given by -config, which override the defaults. A single
+// positional argument is still accepted as the number of players.
+func Parse(name string, args []string, output io.Writer) (cfg Config, printConfig bool, err error) {
+	var path string
+	scratch := Default()
+	fs := newFlagSet(name, &scratch, &path, &printConfig)
+	fs.SetOutput(io.Discard)
+	if err = fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fs = newFlagSet(name, &scratch, &path, &printConfig)
+			fs.SetOutput(output)
+			fs.Usage()
+		}
+		return
+	}
+
+	cfg = Default()
+	if path != "" {
+		if cfg, err = Load(path); err != nil {
+			return
+		}
+	}
+	fs = newFlagSet(name, &cfg, &path, &printConfig)
+	fs.SetOutput(output)
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	if fs.NArg() > 0 {
+		n, convErr := strconv.Atoi(fs.Arg(0))
+		if convErr != nil {
+			err = fmt.Errorf("invalid number of players: %s", fs.Arg(0))
+			return
+		}
+		cfg.Rules.NumPlayers = n
+	}
+	err = cfg.Validate()
+	return
+}
+
+func newFlagSet(name string, cfg *Config, path *string, printConfig *bool) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s [flags] [players]\n", name)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(path, "config", "", "path to the config file, only json is supported")
+	fs.BoolVar(printConfig, "print-config", false, "print the resulting config and exit")
+	fs.StringVar(&cfg.Listen, "listen", cfg.Listen, "address of the game server")
+	fs.BoolVar(&cfg.TLS.Enabled, "tls", cfg.TLS.Enabled, "encrypt the connections of the game server")
+	fs.StringVar(&cfg.TLS.Cert, "tls-cert", cfg.TLS.Cert, "tls certificate file, a self-signed certificate is generated without one")
+	fs.StringVar(&cfg.TLS.Key, "tls-key", cfg.TLS.Key, "tls key file")
+	fs.StringVar(&cfg.WebListen, "web", cfg.WebListen, "address of the web client, empty to disable")
+	fs.StringVar(&cfg.AdminListen, "admin", cfg.AdminListen, "address of the admin api and metrics, empty to disable")
+	fs.StringVar(&cfg.AdminToken, "admin-token", cfg.AdminToken, "token required by the admin api")
+	fs.StringVar(&cfg.LogFile, "log", cfg.LogFile, "log file, - for stderr")
+	fs.StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "log level: debug or info")
+	fs.Var(&cfg.IdleTimeout, "idle-timeout", "disconnect clients idle for this long")
+	fs.IntVar(&cfg.Rules.NumPlayers, "players", cfg.Rules.NumPlayers, "number of players per game, only 3 is supported")
+	fs.Var(&cfg.Rules.TurnTimeout, "turn-timeout", "pass the turn of players idle for this long, 0 to disable")
+	fs.BoolVar(&cfg.Rules.Bidding, "bidding", cfg.Rules.Bidding, "let the players bid for becoming the landlord")
+	fs.IntVar(&cfg.Limits.ConnectionsPerIP, "max-conns", cfg.Limits.ConnectionsPerIP, "connections allowed per ip address, 0 for no limit")
+	fs.Float64Var(&cfg.Limits.CommandRate, "command-rate", cfg.Limits.CommandRate, "commands per second allowed per client, 0 for no limit")
+	fs.Float64Var(&cfg.Limits.ChatRate, "chat-rate", cfg.Limits.ChatRate, "chat messages per second allowed per client, 0 for no limit")
+	fs.StringVar(&cfg.DataDir, "data", cfg.DataDir, "directory for persistent data")
+	fs.StringVar(&cfg.ConsoleSocket, "console-socket", cfg.ConsoleSocket, "unix socket for the operator console, empty to disable")
+	return fs
+}
+
+func (c Config) Validate() error {
+	var errs []error
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		errs = append(errs, fmt.Errorf("listen: invalid address %q", c.Listen))
+	}
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		errs = append(errs, errors.New("tls: cert and key must be set together"))
+	} else if c.TLS.Cert != "" && !c.TLS.Enabled {
+		errs = append(errs, errors.New("tls: cert and key are set but tls is not enabled"))
+	}
+	// the web client and the admin api are optional
+	if _, _, err := net.SplitHostPort(c.WebListen); c.WebListen != "" && err != nil {
+		errs = append(errs, fmt.Errorf("web_listen: invalid address %q", c.WebListen))
+	}
+	if _, _, err := net.SplitHostPort(c.AdminListen); c.AdminListen != "" && err != nil {
+		errs = append(errs, fmt.Errorf("admin_listen: invalid address %q", c.AdminListen))
+	}
+	if c.LogFile == "" {
+		errs = append(errs, errors.New("log_file: must not be empty"))
+	}
+	if c.LogLevel != LEVEL_DEBUG && c.LogLevel != LEVEL_INFO {
+		errs = append(errs, fmt.Errorf("log_level: unknown level %q", c.LogLevel))
+	}
+	if c.IdleTimeout <= 0 {
+		errs = append(errs, errors.New("idle_timeout: must be positive"))
+	}
+	if err := c.Rules.Validate(); err != nil {
+		for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+			errs = append(errs, fmt.Errorf("rules.%w", err))
+		}
+	}
+	l := c.Limits
+	if l.ConnectionsPerIP < 0 {
+		errs = append(errs, errors.New("limits.connections_per_ip: must not be negative"))
+	}
+	if l.CommandRate < 0 || l.CommandBurst < 0 {
+		errs = append(errs, errors.New("limits.command_rate: rate and burst must not be negative"))
+	} else if l.CommandRate > 0 && l.CommandBurst < 1 {
+		errs = append(errs, errors.New("limits.command_burst: must be at least 1"))
+	}
+	if l.ChatRate < 0 || l.ChatBurst < 0 {
+		errs = append(errs, errors.New("limits.chat_rate: rate and burst must not be negative"))
+	} else if l.ChatRate > 0 && l.ChatBurst < 1 {
+		errs = append(errs, errors.New("limits.chat_burst: must be at least 1"))
+	}
+	if c.DataDir == "" {
+		errs = append(errs, errors.New("data_dir: must not be empty"))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks the rules, which can also be changed for a single room.
+func (r Rules) Validate() error {
+	var errs []error
+	// the deal, the bottom cards and the scoring of the farmers all need
+	// three seats
+	if r.NumPlayers != 3 {
+		errs = append(errs, errors.New("num_players: must be 3"))
+	}
+	if r.TurnTimeout < 0 {
+		errs = append(errs, errors.New("turn_timeout: must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+func (c Config) Print(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{"listen": "127.0.0.1:9000", "log_level": "debug", "rules": {"turn_timeout": "20s"}}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _, err := Parse("landlord", []string{"-config", path, "-log-level", "info", "3"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Listen != "127.0.0.1:9000" {
+		t.Errorf("listen from file was not applied: %s", cfg.Listen)
+	}
+	if cfg.LogLevel != LEVEL_INFO {
+		t.Errorf("flag did not override file: %s", cfg.LogLevel)
+	}
+	if cfg.Rules.TurnTimeout.Duration() != 20*time.Second {
+		t.Errorf("turn timeout from file was not applied: %v", cfg.Rules.TurnTimeout)
+	}
+	if cfg.DataDir != Default().DataDir {
+		t.Errorf("default was not kept: %s", cfg.DataDir)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("default config is invalid: %s", err)
+	}
+	cfg.WebListen = ""
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("disabling the web client should be valid: %s", err)
+	}
+	for _, n := range []int{1, 2, 4} {
+		rules := cfg.Rules
+		rules.NumPlayers = n
+		if err := rules.Validate(); err == nil {
+			t.Errorf("expected %d players to be rejected", n)
+		}
+	}
+	if _, _, err := Parse("landlord", []string{"2"}, io.Discard); err == nil {
+		t.Error("expected a positional number of players other than 3 to be rejected")
+	}
+	cfg.Listen = "8888"
+	cfg.Rules.NumPlayers = 4
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected invalid address and number of players to be rejected")
+	}
+}
+
+func TestValidateTLS(t *testing.T) {
+	cfg := Default()
+	cfg.TLS.Enabled = true
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("tls without a certificate should generate one: %s", err)
+	}
+	cfg.TLS.Cert = "cert.pem"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected a certificate without key to be rejected")
+	}
+	cfg.TLS.Key = "key.pem"
+	cfg.TLS.Enabled = false
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected a certificate without tls to be rejected")
+	}
+}
@@ -6,7 +6,9 @@ require golang.org/x/exp v0.0.0-20230905200255-921286631fa9
 
 require (
 	github.com/gdamore/tcell/v2 v2.6.0
+	github.com/gorilla/websocket v1.5.0
 	github.com/rivo/tview v0.0.0-20230928053139-9bc1d28d88a9
+	golang.org/x/crypto v0.0.0-20220525230936-793ad666bf5e
 )
 
 require (
@@ -1,53 +1,152 @@
 package main
 
 import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"landlord/config"
+	"landlord/server"
 	"log"
 	"net"
+	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"syscall"
 	"time"
-	"landlord/server"
 )
 
 func main() {
-	f, err := os.OpenFile("server.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0777)
+	cfg, printConfig, err := parseConfig(os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		log.Fatalf("unable to open log file: %s", err.Error())
+		fmt.Fprintf(os.Stderr, "invalid config:\n%s\n", err.Error())
+		os.Exit(2)
+	}
+	if printConfig {
+		cfg.Print(os.Stdout)
+		return
+	}
+
+	if cfg.LogFile != "-" {
+		f, err := os.OpenFile(cfg.LogFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0777)
+		if err != nil {
+			log.Fatalf("unable to open log file: %s", err.Error())
+		}
+		defer f.Close()
+		log.SetOutput(f)
 	}
-	defer f.Close()
-	log.SetOutput(f)
 	log.Println("______________________")
-	listen, err := net.Listen("tcp", "0.0.0.0:8888")
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		log.Fatalf("unable to create data directory: %s", err.Error())
+	}
+	listen, err := net.Listen("tcp", cfg.Listen)
 	if err != nil {
 		log.Fatalf("unable to start server: %s", err.Error())
 	}
+	if cfg.TLS.Enabled {
+		host, _, _ := net.SplitHostPort(cfg.Listen)
+		tlsConfig, err := server.TLSConfig(cfg.TLS, cfg.DataDir, host)
+		if err != nil {
+			log.Fatalf("unable to set up tls: %s", err.Error())
+		}
+		listen = tls.NewListener(listen, tlsConfig)
+	}
 	defer listen.Close()
-	log.Println("server started on port 8888")
+	log.Printf("server started on %s (tls: %v)", cfg.Listen, cfg.TLS.Enabled)
 
 	server := server.NewServer()
-
-	args := os.Args[1:]
-	if len(args) > 0 {
-		if n, err := strconv.Atoi(args[0]); err == nil {
-			server.SetNumPlayers(n)
-		}
+	server.Configure(cfg)
+	if err := server.Load(); err != nil {
+		log.Fatalf("unable to load data: %s", err.Error())
+	}
+	if err := server.Restore(); err != nil {
+		log.Printf("unable to restore the interrupted game: %s", err.Error())
 	}
 
 	go server.RunCommands()
 	go server.GameLoop()
-	go server.RemoveClosedClient()
+	go server.Matchmake()
+
+	server.SetReload(func() (config.Config, error) {
+		cfg, _, err := parseConfig(io.Discard)
+		return cfg, err
+	})
+	go server.Console(os.Stdin, os.Stdout)
+	if cfg.ConsoleSocket != "" {
+		console, err := server.ListenConsole(cfg.ConsoleSocket)
+		if err != nil {
+			log.Fatalf("unable to start console: %s", err.Error())
+		}
+		defer os.Remove(cfg.ConsoleSocket)
+		defer console.Close()
+		log.Printf("console served on %s", cfg.ConsoleSocket)
+	}
+
+	var httpServers []*http.Server
+	if cfg.WebListen != "" {
+		web := &http.Server{Addr: cfg.WebListen, Handler: server.WebHandler()}
+		httpServers = append(httpServers, web)
+		go func() {
+			log.Printf("web client served on %s", cfg.WebListen)
+			if err := web.ListenAndServe(); err != http.ErrServerClosed {
+				log.Printf("unable to start web server: %s", err.Error())
+			}
+		}()
+	}
+
+	if cfg.AdminListen != "" {
+		admin := &http.Server{Addr: cfg.AdminListen, Handler: server.AdminHandler(cfg.AdminToken)}
+		httpServers = append(httpServers, admin)
+		go func() {
+			log.Printf("admin api served on %s", cfg.AdminListen)
+			if err := admin.ListenAndServe(); err != http.ErrServerClosed {
+				log.Printf("unable to start admin api: %s", err.Error())
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Printf("received %v, shutting down", sig)
+		listen.Close()
+		for _, srv := range httpServers {
+			srv.Close()
+		}
+		server.Shutdown(fmt.Sprintf("received %v", sig))
+		close(done)
+	}()
 
 	for {
 		conn, err := listen.Accept()
-		if err != nil {
-			log.Printf("unable to accept connection: %s", err.Error())
+		if errors.Is(err, net.ErrClosed) {
+			break
 		}
-		conn.SetDeadline(time.Now().Add(300 * time.Second))
 		if err != nil {
-			log.Printf("unable to set keep alive: %s", err.Error())
+			log.Printf("unable to accept connection: %s", err.Error())
+			continue
 		}
+		conn.SetDeadline(time.Now().Add(cfg.IdleTimeout.Duration()))
 
 		log.Printf("client has connected: %s", conn.RemoteAddr().String())
 		go server.NewClient(conn)
 	}
+	<-done
+	log.Println("server stopped")
+}
+
+// parseConfig reads the config from the command line, the admin token can
+// also be set in the environment.
+func parseConfig(output io.Writer) (cfg config.Config, printConfig bool, err error) {
+	cfg, printConfig, err = config.Parse(os.Args[0], os.Args[1:], output)
+	if err == nil && cfg.AdminToken == "" {
+		cfg.AdminToken = os.Getenv("LANDLORD_ADMIN_TOKEN")
+	}
+	return
 }
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	ACCOUNTS_FILE   = "accounts.json"
+	HASH_ITERATIONS = 100000
+	// MAX_HASH_ITERATIONS bounds the cost of a stored hash, which a login
+	// has to pay
+	MAX_HASH_ITERATIONS = 10 * HASH_ITERATIONS
+	MIN_PASSWORD_SIZE   = 6
+	MAX_NICK_SIZE       = 16
+)
+
+var (
+	ErrNickTaken     = errors.New("nickname is already registered")
+	ErrWrongPassword = errors.New("wrong nickname or password")
+)
+
+type account struct {
+	Nick     string    `json:"nick"`
+	Password string    `json:"password"`
+	Created  time.Time `json:"created"`
+}
+
+// accounts is the registry of registered players, saved as json in the data
+// directory.
+type accounts struct {
+	mu    sync.Mutex
+	path  string
+	users map[string]*account
+}
+
+func loadAccounts(dir string) (*accounts, error) {
+	a := &accounts{
+		path:  filepath.Join(dir, ACCOUNTS_FILE),
+		users: make(map[string]*account),
+	}
+	byts, err := os.ReadFile(a.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return a, err
+	}
+	var users []*account
+	if err := json.Unmarshal(byts, &users); err != nil {
+		return a, fmt.Errorf("unable to parse %s: %w", a.path, err)
+	}
+	for _, user := range users {
+		a.users[strings.ToLower(user.Nick)] = user
+	}
+	return a, nil
+}
+
+func (a *accounts) save() error {
+	var users []*account
+	for _, user := range a.users {
+		users = append(users, user)
+	}
+	byts, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return err
+	}
+	tmp := a.path + ".tmp"
+	if err := os.WriteFile(tmp, byts, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.path)
+}
+
+// registered reports whether nick belongs to an account. Nicknames are
+// compared case insensitively.
+func (a *accounts) registered(nick string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, ok := a.users[strings.ToLower(nick)]
+	return ok
+}
+
+func (a *accounts) register(nick, password string) error {
+	if len(password) < MIN_PASSWORD_SIZE {
+		return fmt.Errorf("password must have at least %d characters", MIN_PASSWORD_SIZE)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.users[strings.ToLower(nick)]; ok {
+		return ErrNickTaken
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	a.users[strings.ToLower(nick)] = &account{nick, hash, time.Now()}
+	if err := a.save(); err != nil {
+		delete(a.users, strings.ToLower(nick))
+		return err
+	}
+	return nil
+}
+
+// login checks the password and returns the nickname as it was registered.
+func (a *accounts) login(nick, password string) (string, error) {
+	a.mu.Lock()
+	user, ok := a.users[strings.ToLower(nick)]
+	a.mu.Unlock()
+	if !ok || !checkPassword(user.Password, password) {
+		return "", ErrWrongPassword
+	}
+	return user.Nick, nil
+}
+
+func validNick(nick string) error {
+	switch {
+	case nick == "":
+		return errors.New("nickname must not be empty")
+	case len(nick) > MAX_NICK_SIZE:
+		return fmt.Errorf("nickname must have at most %d characters", MAX_NICK_SIZE)
+	case strings.ContainsAny(nick, " \t_"):
+		return errors.New("nickname must not contain spaces or underscores")
+	case nick[0] == '#' || nick[0] == '/':
+		return errors.New("nickname must not start with # or /")
+	}
+	return nil
+}
+
+// hashPassword derives a key from password with PBKDF2-HMAC-SHA256 and a
+// random salt. The result holds everything needed to check the password.
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := pbkdf2.Key([]byte(password), salt, HASH_ITERATIONS, sha256.Size, sha256.New)
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", HASH_ITERATIONS,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// checkPassword reports whether password matches hash. Hashes weaker than
+// HASH_ITERATIONS or costlier than MAX_HASH_ITERATIONS are rejected, as they
+// can only come from a corrupt or edited accounts file.
+func checkPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < HASH_ITERATIONS || iterations > MAX_HASH_ITERATIONS {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(key) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, pbkdf2.Key([]byte(password), salt, iterations, len(key), sha256.New)) == 1
+}
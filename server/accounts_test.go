@@ -0,0 +1,55 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAccounts(t *testing.T) {
+	dir := t.TempDir()
+	a, err := loadAccounts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.register("Alice", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.register("alice", "another"); err != ErrNickTaken {
+		t.Errorf("expected nickname to be taken, got %v", err)
+	}
+	if err := a.register("bob", "short"); err == nil {
+		t.Error("expected short password to be rejected")
+	}
+
+	a, err = loadAccounts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nick, err := a.login("ALICE", "secret"); err != nil || nick != "Alice" {
+		t.Errorf("login failed: %q %v", nick, err)
+	}
+	if _, err := a.login("alice", "wrong"); err != ErrWrongPassword {
+		t.Errorf("expected wrong password, got %v", err)
+	}
+	if _, err := a.login("bob", "secret"); err != ErrWrongPassword {
+		t.Errorf("expected unknown account to be rejected, got %v", err)
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	// derived from "secret" by another implementation of PBKDF2-HMAC-SHA256
+	hash := "pbkdf2-sha256$100000$bGFuZGxvcmQtc2FsdC0xNg$PKZxLAWsttEmGaBAxBs+LbtgqlIMGHyPLv3OajAUd3U"
+	if !checkPassword(hash, "secret") {
+		t.Error("expected the password to match the hash")
+	}
+	if checkPassword(hash, "secreT") {
+		t.Error("expected another password not to match")
+	}
+	for _, iterations := range []int{0, 1, HASH_ITERATIONS - 1, MAX_HASH_ITERATIONS + 1, 1 << 40} {
+		tampered := strings.Replace(hash, "$100000$", "$"+strconv.Itoa(iterations)+"$", 1)
+		if checkPassword(tampered, "secret") {
+			t.Errorf("expected a hash of %d iterations to be rejected", iterations)
+		}
+	}
+}
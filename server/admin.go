@@ -0,0 +1,412 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"landlord/server/util"
+)
+
+type memberStatus struct {
+	Nick       string `json:"nick"`
+	Addr       string `json:"addr"`
+	Registered bool   `json:"registered"`
+	Room       string `json:"room"`
+	InGame     bool   `json:"in_game"`
+}
+
+type playerStatus struct {
+	Seat     int    `json:"seat"`
+	Nick     string `json:"nick"`
+	Position string `json:"position"`
+	Ready    bool   `json:"ready"`
+	Cards    int    `json:"cards"`
+}
+
+type roomStatus struct {
+	Name        string         `json:"name"`
+	Private     bool           `json:"private"`
+	Owner       string         `json:"owner,omitempty"`
+	Locked      bool           `json:"locked"`
+	State       string         `json:"state"`
+	NumPlayers  int            `json:"num_players"`
+	Players     []playerStatus `json:"players"`
+	CurrentTurn string         `json:"current_turn,omitempty"`
+	Landlord    string         `json:"landlord,omitempty"`
+	LastPlayed  string         `json:"last_played,omitempty"`
+	LastPlayer  string         `json:"last_player,omitempty"`
+}
+
+type queueStatus struct {
+	Nick    string `json:"nick"`
+	Rating  int    `json:"rating"`
+	Waiting string `json:"waiting"`
+}
+
+type serverStatus struct {
+	Uptime  string         `json:"uptime"`
+	Members []memberStatus `json:"members"`
+	Rooms   []roomStatus   `json:"rooms"`
+	Queue   []queueStatus  `json:"queue"`
+}
+
+// AdminHandler serves the prometheus metrics and the admin and status api.
+// Every api request has to carry the configured token, either as a bearer
+// token or as the token query parameter. The api is disabled if token is
+// empty.
+func (s *server) AdminHandler(token string) http.Handler {
+	api := http.NewServeMux()
+	api.HandleFunc("/api/status", s.handleStatus)
+	api.HandleFunc("/api/kick", s.handleKick)
+	api.HandleFunc("/api/end", s.handleEndGame)
+	api.HandleFunc("/api/broadcast", s.handleBroadcast)
+	api.HandleFunc("/api/tournament", s.handleTournament)
+	api.HandleFunc("/api/tournament/start", s.handleStartTournament)
+	api.HandleFunc("/api/bans", s.handleBans)
+	api.HandleFunc("/api/unban", s.handleUnban)
+	api.HandleFunc("/api/violations", s.handleViolations)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	mux.Handle("/api/", requireToken(token, api))
+	return mux
+}
+
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			writeError(w, http.StatusForbidden, "admin api is disabled")
+			return
+		}
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if given == "" {
+			given = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			log.Printf("admin: rejected request from %s", r.RemoteAddr)
+			writeError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	s.mu.Lock()
+	status := s.status()
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *server) status() serverStatus {
+	status := serverStatus{
+		Uptime:  time.Since(s.started).Round(time.Second).String(),
+		Members: []memberStatus{},
+		Rooms:   []roomStatus{},
+		Queue:   []queueStatus{},
+	}
+	s.members.Range(func(_, member any) bool {
+		c := member.(*client)
+		if c.Nick == "#anonymous" || c.room == nil {
+			return true
+		}
+		status.Members = append(status.Members, memberStatus{
+			Nick:       c.Nick,
+			Addr:       c.Conn.RemoteAddr().String(),
+			Registered: c.Account,
+			Room:       c.room.name,
+			InGame:     c.room.game.ContainsPlayer(c.ID),
+		})
+		return true
+	})
+	s.rooms.Range(func(_, r any) bool {
+		status.Rooms = append(status.Rooms, r.(*room).status())
+		return true
+	})
+	sort.Slice(status.Rooms, func(i, j int) bool {
+		return status.Rooms[i].Name < status.Rooms[j].Name
+	})
+	for _, e := range s.queue.list() {
+		status.Queue = append(status.Queue, queueStatus{
+			Nick:    e.c.Nick,
+			Rating:  e.rating,
+			Waiting: time.Since(e.since).Round(time.Second).String(),
+		})
+	}
+	return status
+}
+
+func (r *room) status() roomStatus {
+	g := r.game
+	status := roomStatus{
+		Name:       r.name,
+		Private:    r.code != "",
+		Locked:     r.locked,
+		State:      util.State(g.State),
+		NumPlayers: g.NumPlayers,
+		Players:    []playerStatus{},
+	}
+	seated := g.Order
+	if g.State == util.STATE_WAITING || seated == nil {
+		seated = g.Seated()
+	}
+	for _, p := range seated {
+		position := "farmer"
+		if g.State == util.STATE_PLAYING && p.Position == util.LANDLORD {
+			position = "landlord"
+		}
+		status.Players = append(status.Players, playerStatus{
+			Seat:     p.Seat + 1,
+			Nick:     p.Nick,
+			Position: position,
+			Ready:    p.IsReady,
+			Cards:    len(p.Cards),
+		})
+	}
+	if r.owner != nil {
+		status.Owner = r.owner.Nick
+	}
+	if g.State == util.STATE_PLAYING {
+		if g.CurrentPlayer != nil {
+			status.CurrentTurn = g.CurrentPlayer.Nick
+		}
+		if g.Landlord != nil {
+			status.Landlord = g.Landlord.Nick
+		}
+		if g.LastPlayer != nil && len(g.LastUsedCards) > 0 {
+			status.LastPlayed = "[" + util.FormatCards(g.LastUsedCards) + "]"
+			status.LastPlayer = g.LastPlayer.Nick
+		}
+	}
+	return status
+}
+
+func (s *server) handleKick(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Nick string `json:"nick"`
+	}
+	if !decodePost(w, r, &req) {
+		return
+	}
+	c := s.findClient(req.Nick)
+	if c == nil {
+		writeError(w, http.StatusNotFound, "no such player: "+req.Nick)
+		return
+	}
+	log.Printf("admin: kicking %s (%v)", c.Nick, c.Conn.RemoteAddr())
+	s.commands <- command{CMD_KICK, c, nil}
+	writeJSON(w, http.StatusOK, map[string]string{"result": "kicked " + c.Nick})
+}
+
+func (s *server) handleEndGame(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Room string `json:"room"`
+	}
+	if !decodePost(w, r, &req) {
+		return
+	}
+	if req.Room == "" {
+		req.Room = MAIN_ROOM
+	}
+	room := s.room(req.Room)
+	if room == nil {
+		writeError(w, http.StatusNotFound, "no such room: "+req.Room)
+		return
+	}
+	s.mu.Lock()
+	playing := room.game.State == util.STATE_PLAYING || room.resume != nil
+	s.mu.Unlock()
+	if !playing {
+		writeError(w, http.StatusConflict, "no game in progress")
+		return
+	}
+	log.Printf("admin: ending the game in %s", room.name)
+	s.commands <- command{CMD_END_GAME, nil, []string{room.name}}
+	writeJSON(w, http.StatusOK, map[string]string{"result": "game ended"})
+}
+
+func (s *server) handleBroadcast(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Text string `json:"text"`
+	}
+	if !decodePost(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		writeError(w, http.StatusBadRequest, "text must not be empty")
+		return
+	}
+	log.Printf("admin: broadcasting notice: %s", req.Text)
+	s.commands <- command{CMD_NOTICE, nil, []string{req.Text}}
+	writeJSON(w, http.StatusOK, map[string]string{"result": "notice sent"})
+}
+
+// handleTournament opens a tournament for registration on POST and returns
+// a tournament on GET, the current one unless another is named. The
+// standings are exported as csv with format=csv.
+func (s *server) handleTournament(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		t, err := s.tournaments.find(r.URL.Query().Get("name"))
+		if errors.Is(err, ErrNoTournament) {
+			writeError(w, http.StatusNotFound, "no such tournament")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if r.URL.Query().Get("format") == "csv" {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", t.Name+".csv"))
+			writeStandingsCSV(w, t)
+			return
+		}
+		writeJSON(w, http.StatusOK, struct {
+			tournament
+			Standings []standing `json:"standings"`
+		}{t, t.standings()})
+		return
+	}
+
+	var req struct {
+		Name   string `json:"name"`
+		Rounds int    `json:"rounds"`
+		Deals  int    `json:"deals"`
+	}
+	if !decodePost(w, r, &req) {
+		return
+	}
+	if err := s.tournaments.open(req.Name, req.Rounds, req.Deals); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	log.Printf("admin: opened the tournament %s (%d rounds of %d deals)", req.Name, req.Rounds, req.Deals)
+	s.commands <- command{CMD_NOTICE, nil, []string{fmt.Sprintf(
+		"the tournament %s is open, type /tournament join to take part", req.Name)}}
+	writeJSON(w, http.StatusOK, map[string]string{"result": "tournament opened"})
+}
+
+func (s *server) handleStartTournament(w http.ResponseWriter, r *http.Request) {
+	if !decodePost(w, r, nil) {
+		return
+	}
+	s.mu.Lock()
+	numPlayers := s.rules.NumPlayers
+	s.mu.Unlock()
+	if err := s.tournaments.canStart(numPlayers); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	log.Println("admin: starting the tournament")
+	s.commands <- command{CMD_START_TOURNAMENT, nil, nil}
+	writeJSON(w, http.StatusOK, map[string]string{"result": "tournament started"})
+}
+
+// handleBans lists the bans on GET and bans an IP address or a nickname on
+// POST, disconnecting the players it matches.
+func (s *server) handleBans(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, s.bans.list())
+		return
+	}
+	var req struct {
+		Target string `json:"target"`
+	}
+	if !decodePost(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Target) == "" {
+		writeError(w, http.StatusBadRequest, "target must not be empty")
+		return
+	}
+	banned, err := s.ban(req.Target)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Printf("admin: banned %s", req.Target)
+	nicks := []string{}
+	for _, c := range banned {
+		nicks = append(nicks, c.Nick)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"result": "banned " + req.Target, "disconnected": nicks})
+}
+
+func (s *server) handleUnban(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Target string `json:"target"`
+	}
+	if !decodePost(w, r, &req) {
+		return
+	}
+	removed, err := s.bans.remove(req.Target)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !removed {
+		writeError(w, http.StatusNotFound, "not banned: "+req.Target)
+		return
+	}
+	log.Printf("admin: lifted the ban of %s", req.Target)
+	writeJSON(w, http.StatusOK, map[string]string{"result": "lifted the ban of " + req.Target})
+}
+
+func (s *server) handleViolations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.violations.list())
+}
+
+func (s *server) findClient(nick string) (found *client) {
+	s.members.Range(func(_, c any) bool {
+		if strings.EqualFold(c.(*client).Nick, nick) && nick != "#anonymous" {
+			found = c.(*client)
+			return false
+		}
+		return true
+	})
+	return
+}
+
+// decodePost rejects anything but POST requests and decodes the json body
+// into v, if v is not nil. An empty body leaves v unchanged. It reports
+// whether the request can be handled.
+func decodePost(w http.ResponseWriter, r *http.Request, v any) bool {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return false
+	}
+	if v == nil {
+		return true
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const BANS_FILE = "bans.json"
+
+type ban struct {
+	Target  string    `json:"target"`
+	Created time.Time `json:"created"`
+}
+
+// bans are the IP addresses and nicknames not allowed on the server, saved
+// as json in the data directory. Banning a nickname also bans the account.
+type bans struct {
+	mu    sync.Mutex
+	path  string
+	ips   map[string]*ban
+	nicks map[string]*ban
+}
+
+type banList struct {
+	IPs   []*ban `json:"ips"`
+	Nicks []*ban `json:"nicks"`
+}
+
+func newBans(dir string) *bans {
+	return &bans{
+		path:  filepath.Join(dir, BANS_FILE),
+		ips:   make(map[string]*ban),
+		nicks: make(map[string]*ban),
+	}
+}
+
+func loadBans(dir string) (*bans, error) {
+	b := newBans(dir)
+	byts, err := os.ReadFile(b.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return b, err
+	}
+	var list banList
+	if err := json.Unmarshal(byts, &list); err != nil {
+		return b, fmt.Errorf("unable to parse %s: %w", b.path, err)
+	}
+	for _, ban := range append(list.IPs, list.Nicks...) {
+		entries, key := b.entries(ban.Target)
+		entries[key] = ban
+	}
+	return b, nil
+}
+
+func (b *bans) save() error {
+	byts, err := json.MarshalIndent(b.listLocked(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return err
+	}
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, byts, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.path)
+}
+
+// add bans target, which is either an IP address or a nickname. It reports
+// whether target is an IP address.
+func (b *bans) add(target string) (ip bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ip = net.ParseIP(target) != nil
+	entries, key := b.entries(target)
+	if _, ok := entries[key]; ok {
+		return ip, nil
+	}
+	entries[key] = &ban{target, time.Now()}
+	if err := b.save(); err != nil {
+		delete(entries, key)
+		return ip, err
+	}
+	return ip, nil
+}
+
+// remove lifts the ban of target. It reports whether target was banned.
+func (b *bans) remove(target string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	entries, key := b.entries(target)
+	removed, ok := entries[key]
+	if !ok {
+		return false, nil
+	}
+	delete(entries, key)
+	if err := b.save(); err != nil {
+		entries[key] = removed
+		return false, err
+	}
+	return true, nil
+}
+
+// entries are the bans target belongs to, with its key.
+func (b *bans) entries(target string) (map[string]*ban, string) {
+	if addr := net.ParseIP(target); addr != nil {
+		return b.ips, addr.String()
+	}
+	return b.nicks, strings.ToLower(target)
+}
+
+func (b *bans) ip(addr net.Addr) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.ips[hostOf(addr)]
+	return ok
+}
+
+func (b *bans) nick(nick string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.nicks[strings.ToLower(nick)]
+	return ok
+}
+
+func (b *bans) list() banList {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.listLocked()
+}
+
+func (b *bans) listLocked() banList {
+	list := banList{IPs: []*ban{}, Nicks: []*ban{}}
+	for _, ban := range b.ips {
+		list.IPs = append(list.IPs, ban)
+	}
+	for _, ban := range b.nicks {
+		list.Nicks = append(list.Nicks, ban)
+	}
+	for _, bans := range [][]*ban{list.IPs, list.Nicks} {
+		sort.Slice(bans, func(i, j int) bool {
+			return bans[i].Created.Before(bans[j].Created)
+		})
+	}
+	return list
+}
+
+// hostOf is the IP address of a remote address, without the port.
+func hostOf(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return host
+}
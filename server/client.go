@@ -3,28 +3,69 @@ package server
 import (
 	"bufio"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
 	"net"
 	"strings"
+	"sync"
 	"time"
+
+	"landlord/server/util"
+)
+
+const (
+	// OUTBOUND_QUEUE_SIZE is the number of messages queued for a client, a
+	// client falling further behind is disconnected
+	OUTBOUND_QUEUE_SIZE = 256
+	// WRITE_TIMEOUT is the time a client has to take a message
+	WRITE_TIMEOUT = 10 * time.Second
 )
 
+var ErrDisconnected = errors.New("the client is disconnected")
+
 type client struct {
-	Nick     string `json:"nick"`
+	// ID is the player ID of the session
+	ID   util.PlayerID `json:"id"`
+	Nick string        `json:"nick"`
+	// Account is set for players logged in to a registered account
+	Account  bool `json:"account"`
 	commands chan<- command
 	Conn     net.Conn `json:"conn"`
+	// out queues the messages for writeOutput, written is closed once it's
+	// done. writes guards out, which nothing is queued to once closed
+	out     chan []byte
+	written chan struct{}
+	writes  sync.Mutex
+	closed  bool
+	metrics *metrics
+	room    *room
+
+	commandLimit *limiter
+	chatLimit    *limiter
+	violations   *violations
 }
 
-func (c *client) readInput() {
-	reader := bufio.NewReader(c.Conn)
+func (c *client) readInput(reader *bufio.Reader) {
 	for {
 		msg, err := reader.ReadString('\n')
 		if err != nil {
 			return
 		}
 		msg = strings.Trim(msg, "\r\n ")
-		log.Printf("%v (%v) -> %v", c.Nick, c.Conn.RemoteAddr(), msg)
+		debugf("%v (%v) -> %v", c.Nick, c.Conn.RemoteAddr(), msg)
+		if c.throttle(c.commandLimit, VIOLATION_COMMANDS) {
+			if c.commandLimit.dropped == 1 {
+				c.err(errors.New("> you're sending commands too fast, slow down"))
+			}
+			continue
+		}
 		if len(msg) > 0 && msg[0] != '/' {
+			if c.throttle(c.chatLimit, VIOLATION_CHAT) {
+				if c.chatLimit.dropped == 1 {
+					c.err(errors.New("> you're chatting too fast, your messages are dropped for a moment"))
+				}
+				continue
+			}
 			c.commands <- command{CMD_MESSAGE, c, []string{msg}}
 			continue
 		}
@@ -48,6 +89,26 @@ func (c *client) readInput() {
 			c.commands <- command{CMD_USE_CARDS, c, args}
 		case "/pass":
 			c.commands <- command{CMD_PASS, c, args}
+		case "/bid":
+			c.commands <- command{CMD_BID, c, args}
+		case "/stats":
+			c.commands <- command{CMD_STATS, c, args}
+		case "/leaderboard":
+			c.commands <- command{CMD_LEADERBOARD, c, args}
+		case "/rooms":
+			c.commands <- command{CMD_ROOMS, c, args}
+		case "/join":
+			c.commands <- command{CMD_JOIN, c, args}
+		case "/create":
+			c.commands <- command{CMD_CREATE, c, args}
+		case "/room":
+			c.commands <- command{CMD_ROOM, c, args}
+		case "/queue":
+			c.commands <- command{CMD_QUEUE, c, args}
+		case "/tournament":
+			c.commands <- command{CMD_TOURNAMENT, c, args}
+		case "/match":
+			c.commands <- command{CMD_MATCH, c, args}
 		default:
 			c.commands <- command{CMD_UNKNOWN, c, args}
 
@@ -65,6 +126,7 @@ const (
 	MSG_CHAT
 	MSG_ROOM_INFO
 	MSG_STOP
+	MSG_STATS
 )
 
 type Message struct {
@@ -74,29 +136,64 @@ type Message struct {
 }
 
 func (c *client) msg(msgType messageType, msg string) (err error) {
-	byts, err := json.Marshal(Message{msgType, msg, c.Nick})
-	if err != nil {
-		return 
-	}
-	_, err = c.Conn.Write([]byte(string(byts) + "\n"))
-	if err != nil {
-		return 
+	if err = c.send(Message{msgType, msg, c.Nick}); err == nil {
+		debugf("%v (%v) <- %v", c.Nick, c.Conn.RemoteAddr(), strings.Trim(msg, "\r\n\b "))
 	}
-	time.Sleep(300 * time.Millisecond)
-	log.Printf("%v (%v) <- %v", c.Nick, c.Conn.RemoteAddr(), strings.Trim(msg, "\r\n\b "))
 	return
 }
 
 func (c *client) err(e error) (err error) {
+	if err = c.send(Message{MSG_INFO, e.Error(), c.Nick}); err == nil {
+		debugf("%v (%v) <- %v", c.Nick, c.Conn.RemoteAddr(), strings.Trim(e.Error(), "\r\n\b "))
+	}
+	return
+}
 
-	byts, err := json.Marshal(Message{MSG_INFO, e.Error(), c.Nick})
+// send queues msg for the writer of c without waiting for the client. A
+// client whose queue is full doesn't keep up and is disconnected.
+func (c *client) send(msg Message) error {
+	byts, err := json.Marshal(msg)
 	if err != nil {
-		return
+		return err
 	}
-	_, err = c.Conn.Write([]byte(string(byts) + "\n"))
-	if err != nil {
-		return
+	c.writes.Lock()
+	defer c.writes.Unlock()
+	if c.closed {
+		return ErrDisconnected
+	}
+	select {
+	case c.out <- append(byts, '\n'):
+		return nil
+	default:
+	}
+	c.closed = true
+	c.violations.add(VIOLATION_SLOW, c.Nick, c.Conn.RemoteAddr(), fmt.Sprintf("more than %d messages queued", OUTBOUND_QUEUE_SIZE))
+	c.Conn.Close()
+	return ErrDisconnected
+}
+
+// writeOutput writes the queued messages, one JSON object per line, until
+// the queue is closed or the client stops taking them. It closes the
+// connection when it's done.
+func (c *client) writeOutput() {
+	defer close(c.written)
+	defer c.Conn.Close()
+	for byts := range c.out {
+		start := time.Now()
+		c.Conn.SetWriteDeadline(start.Add(WRITE_TIMEOUT))
+		if _, err := c.Conn.Write(byts); err != nil {
+			return
+		}
+		c.metrics.messageSent(time.Since(start))
+	}
+}
+
+// close closes the connection of c once the queued messages are written.
+func (c *client) close() {
+	c.writes.Lock()
+	defer c.writes.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.out)
 	}
-	log.Printf("%v (%v) <- %v", c.Nick, c.Conn.RemoteAddr(), strings.Trim(e.Error(), "\r\n\b "))
-	return
 }
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+func pipeClient(t *testing.T) (*client, net.Conn) {
+	t.Helper()
+	conn, other := net.Pipe()
+	t.Cleanup(func() { other.Close() })
+	c := &client{
+		Nick:       "alice",
+		Conn:       conn,
+		out:        make(chan []byte, OUTBOUND_QUEUE_SIZE),
+		written:    make(chan struct{}),
+		metrics:    newMetrics(),
+		violations: &violations{counts: make(map[string]int)},
+	}
+	go c.writeOutput()
+	return c, other
+}
+
+func TestSlowClient(t *testing.T) {
+	c, _ := pipeClient(t)
+	// nothing is read from the other end, the writer blocks on the first
+	// message and the queue fills up
+	var err error
+	for i := 0; i < OUTBOUND_QUEUE_SIZE+2 && err == nil; i++ {
+		err = c.msg(MSG_MESSAGE, "hello")
+	}
+	if err != ErrDisconnected {
+		t.Fatalf("expected the slow client to be disconnected, got %v", err)
+	}
+	select {
+	case <-c.written:
+	case <-time.After(time.Second):
+		t.Fatal("expected the writer to stop")
+	}
+	if c.violations.counts[VIOLATION_SLOW] != 1 {
+		t.Errorf("expected a violation, got %v", c.violations.counts)
+	}
+	if err := c.msg(MSG_MESSAGE, "hello"); err != ErrDisconnected {
+		t.Errorf("expected no more messages to be queued, got %v", err)
+	}
+}
+
+func TestClientClose(t *testing.T) {
+	c, other := pipeClient(t)
+	for _, text := range []string{"one", "two"} {
+		if err := c.msg(MSG_MESSAGE, text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.msg(MSG_STOP, "bye")
+	c.close()
+
+	scanner := bufio.NewScanner(other)
+	var texts []string
+	for scanner.Scan() {
+		var msg Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			t.Fatal(err)
+		}
+		texts = append(texts, msg.Content)
+	}
+	if len(texts) != 3 || texts[2] != "bye" {
+		t.Errorf("expected the queued messages before the connection is closed, got %v", texts)
+	}
+	<-c.written
+}
@@ -10,11 +10,36 @@ const (
 	CMD_VIEW_CARDS
 	CMD_USE_CARDS
 	CMD_PASS
+	CMD_BID
 	CMD_EMPTY_LINE
 	CMD_MESSAGE
 	CMD_UNKNOWN
+	CMD_STATS
+	CMD_LEADERBOARD
+	CMD_ROOMS
+	CMD_JOIN
+	CMD_CREATE
+	CMD_ROOM
+	CMD_QUEUE
+	CMD_TOURNAMENT
+	CMD_MATCH
+	CMD_KICK
+	CMD_END_GAME
+	CMD_NOTICE
+	CMD_MATCHMAKE
+	CMD_CLOSE_ROOM
+	CMD_START_TOURNAMENT
+	CMD_BAN
+	CMD_RELOAD
+	CMD_TURN_TIMEOUT
+	// CMD_LOGIN is sent once the client is authenticated, CMD_DISCONNECTED
+	// once its connection is closed
+	CMD_LOGIN
+	CMD_DISCONNECTED
 )
 
+// command is either sent by a client or issued by an admin or the server
+// itself. Those commands carry the affected client (if any) as sender.
 type command struct {
 	id     commandID
 	sender *client
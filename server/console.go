@@ -0,0 +1,304 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"landlord/config"
+	"landlord/server/util"
+)
+
+const consoleHelp = `console commands:
+   rooms: list the rooms and their games
+   who: list the connected players
+   kick <nickname>: disconnect a player
+   ban <ip|nickname>: disconnect and ban an address or a nickname
+   unban <ip|nickname>: lift a ban
+   bans: list the bans
+   violations: list the recent violations of the bans and limits
+   announce <text>: send a notice to every player
+   endgame <room>: end the game of a room
+   seed <n>: seed the card shuffling, for reproducible deals
+   reload-config: apply the settings of the config again
+   help: show this help`
+
+// SetReload sets how the console reads the config again on reload-config.
+func (s *server) SetReload(reload func() (config.Config, error)) {
+	s.reload = reload
+}
+
+// Console runs the operator console on in until it is closed, writing the
+// results to out.
+func (s *server) Console(in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if err := s.console(out, line); err != nil {
+			fmt.Fprintln(out, "error:", err.Error())
+		}
+	}
+}
+
+// ListenConsole serves the console on a unix socket at path, which only the
+// user running the server can connect to.
+func (s *server) ListenConsole(path string) (net.Listener, error) {
+	os.Remove(path)
+	listen, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listen.Close()
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := listen.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				s.Console(conn, conn)
+			}()
+		}
+	}()
+	return listen, nil
+}
+
+func (s *server) console(out io.Writer, line string) error {
+	args := strings.Fields(line)
+	switch args[0] {
+	case "help":
+		fmt.Fprintln(out, consoleHelp)
+	case "rooms":
+		s.mu.Lock()
+		s.consoleRooms(out)
+		s.mu.Unlock()
+	case "who":
+		s.mu.Lock()
+		s.consoleWho(out)
+		s.mu.Unlock()
+	case "kick":
+		if len(args) != 2 {
+			return errors.New("usage: kick <nickname>")
+		}
+		c := s.findClient(args[1])
+		if c == nil {
+			return errors.New("no such player: " + args[1])
+		}
+		log.Printf("console: kicking %s (%v)", c.Nick, c.Conn.RemoteAddr())
+		s.commands <- command{CMD_KICK, c, nil}
+		fmt.Fprintln(out, "kicked", c.Nick)
+	case "ban":
+		if len(args) != 2 {
+			return errors.New("usage: ban <ip|nickname>")
+		}
+		banned, err := s.ban(args[1])
+		if err != nil {
+			return err
+		}
+		log.Printf("console: banned %s", args[1])
+		fmt.Fprintln(out, "banned", args[1])
+		for _, c := range banned {
+			fmt.Fprintf(out, "disconnected %s (%v)\n", c.Nick, c.Conn.RemoteAddr())
+		}
+	case "unban":
+		if len(args) != 2 {
+			return errors.New("usage: unban <ip|nickname>")
+		}
+		removed, err := s.bans.remove(args[1])
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return errors.New("not banned: " + args[1])
+		}
+		log.Printf("console: lifted the ban of %s", args[1])
+		fmt.Fprintln(out, "lifted the ban of", args[1])
+	case "bans":
+		list := s.bans.list()
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "BANNED\tSINCE")
+		for _, ban := range append(list.IPs, list.Nicks...) {
+			fmt.Fprintf(w, "%s\t%s\n", ban.Target, ban.Created.Format(time.DateTime))
+		}
+		w.Flush()
+	case "violations":
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tKIND\tNICK\tADDRESS\tDETAIL")
+		for _, v := range s.violations.list() {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", v.Time.Format(time.DateTime), v.Kind, v.Nick, v.Addr, v.Detail)
+		}
+		w.Flush()
+	case "announce":
+		text := strings.TrimSpace(strings.TrimPrefix(line, "announce"))
+		if text == "" {
+			return errors.New("usage: announce <text>")
+		}
+		log.Printf("console: broadcasting notice: %s", text)
+		s.commands <- command{CMD_NOTICE, nil, []string{text}}
+		fmt.Fprintln(out, "notice sent")
+	case "endgame":
+		if len(args) != 2 {
+			return errors.New("usage: endgame <room>")
+		}
+		r := s.room(args[1])
+		if r == nil {
+			return errors.New("no such room: " + args[1])
+		}
+		s.mu.Lock()
+		playing := r.game.State == util.STATE_PLAYING || r.resume != nil
+		s.mu.Unlock()
+		if !playing {
+			return errors.New("no game in progress in " + r.name)
+		}
+		log.Printf("console: ending the game in %s", r.name)
+		s.commands <- command{CMD_END_GAME, nil, []string{r.name}}
+		fmt.Fprintln(out, "game ended in", r.name)
+	case "seed":
+		if len(args) != 2 {
+			return errors.New("usage: seed <n>")
+		}
+		seed, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return errors.New("usage: seed <n>")
+		}
+		util.R.Seed(seed)
+		s.mu.Lock()
+		s.reshuffle()
+		s.mu.Unlock()
+		log.Printf("console: seeded the deck with %d", seed)
+		fmt.Fprintln(out, "seeded the deck with", seed)
+	case "reload-config":
+		return s.reloadConfig(out)
+	default:
+		return fmt.Errorf("unknown command %s, type help for a list", args[0])
+	}
+	return nil
+}
+
+// reshuffle shuffles the decks of the games waiting for their players again,
+// which were shuffled when the game before ended, so that the next deals
+// follow the seed. The rooms are taken by name to shuffle in the same order.
+func (s *server) reshuffle() {
+	var rooms []*room
+	s.rooms.Range(func(_, r any) bool {
+		rooms = append(rooms, r.(*room))
+		return true
+	})
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].name < rooms[j].name })
+	for _, r := range rooms {
+		if r.game.State == util.STATE_WAITING {
+			r.game.Deck = util.NewDeck()
+			r.game.Deck.Shuffle()
+		}
+	}
+}
+
+func (s *server) consoleRooms(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ROOM\tSTATE\tPLAYERS\tOWNER\tFLAGS")
+	for _, r := range s.status().Rooms {
+		var flags []string
+		if r.Private {
+			flags = append(flags, "private")
+		}
+		if r.Locked {
+			flags = append(flags, "locked")
+		}
+		var nicks []string
+		for _, p := range r.Players {
+			nicks = append(nicks, p.Nick)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d/%d %s\t%s\t%s\n", r.Name, r.State, len(r.Players), r.NumPlayers,
+			strings.Join(nicks, ","), r.Owner, strings.Join(flags, ","))
+	}
+	w.Flush()
+}
+
+func (s *server) consoleWho(out io.Writer) {
+	status := s.status()
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NICK\tADDRESS\tROOM\tREGISTERED\tIN GAME")
+	for _, m := range status.Members {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%v\n", m.Nick, m.Addr, m.Room, m.Registered, m.InGame)
+	}
+	w.Flush()
+	fmt.Fprintf(out, "%d players connected\n", len(status.Members))
+}
+
+// ban bans target and disconnects the players it matches.
+func (s *server) ban(target string) (banned []*client, err error) {
+	ip, err := s.bans.add(target)
+	if err != nil {
+		return nil, err
+	}
+	s.members.Range(func(_, member any) bool {
+		c := member.(*client)
+		if (ip && s.bans.ip(c.Conn.RemoteAddr())) || (!ip && strings.EqualFold(c.Nick, target)) {
+			banned = append(banned, c)
+			s.commands <- command{CMD_BAN, c, nil}
+		}
+		return true
+	})
+	return banned, nil
+}
+
+// reloadConfig applies the settings that can change while the server runs:
+// the idle timeout, the log level, the rules of new games and the limits of
+// new connections. The other settings need a restart.
+func (s *server) reloadConfig(out io.Writer) error {
+	if s.reload == nil {
+		return errors.New("the config can't be reloaded")
+	}
+	cfg, err := s.reload()
+	if err != nil {
+		return fmt.Errorf("invalid config:\n%w", err)
+	}
+	s.mu.Lock()
+	old := s.config
+	restart := []struct {
+		name    string
+		changed bool
+	}{
+		{"listen", cfg.Listen != old.Listen},
+		{"tls", cfg.TLS != old.TLS},
+		{"web_listen", cfg.WebListen != old.WebListen},
+		{"admin_listen", cfg.AdminListen != old.AdminListen},
+		{"admin_token", cfg.AdminToken != old.AdminToken},
+		{"log_file", cfg.LogFile != old.LogFile},
+		{"data_dir", cfg.DataDir != old.DataDir},
+		{"console_socket", cfg.ConsoleSocket != old.ConsoleSocket},
+	}
+	cfg.Listen, cfg.WebListen, cfg.AdminListen = old.Listen, old.WebListen, old.AdminListen
+	cfg.AdminToken, cfg.LogFile, cfg.DataDir = old.AdminToken, old.LogFile, old.DataDir
+	cfg.TLS, cfg.ConsoleSocket = old.TLS, old.ConsoleSocket
+	s.config = cfg
+	s.idleTimeout = cfg.IdleTimeout.Duration()
+	s.rules = cfg.Rules
+	s.mu.Unlock()
+	debugLogging.Store(cfg.LogLevel == config.LEVEL_DEBUG)
+	s.commands <- command{CMD_RELOAD, nil, nil}
+	log.Printf("console: reloaded the config")
+	fmt.Fprintln(out, "config reloaded, new games use the new rules")
+	for _, setting := range restart {
+		if setting.changed {
+			fmt.Fprintf(out, "%s changed, restart the server to apply it\n", setting.name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"landlord/config"
+)
+
+func TestConsole(t *testing.T) {
+	s := NewServer()
+	s.commands = make(chan command, 10)
+	s.bans = newBans(t.TempDir())
+	var out bytes.Buffer
+	s.Console(strings.NewReader("rooms\nannounce  server restarts soon \nendgame nowhere\nban 10.0.0.1\nfoo\n"), &out)
+
+	if !strings.Contains(out.String(), MAIN_ROOM) {
+		t.Errorf("expected the main room to be listed:\n%s", out.String())
+	}
+	if cmd := <-s.commands; cmd.id != CMD_NOTICE || cmd.args[0] != "server restarts soon" {
+		t.Errorf("unexpected command for announce: %v", cmd)
+	}
+	if !strings.Contains(out.String(), "error: no such room: nowhere") {
+		t.Errorf("expected an unknown room to be rejected:\n%s", out.String())
+	}
+	if !s.bans.ip(&net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}) {
+		t.Error("expected the address to be banned")
+	}
+	if !strings.Contains(out.String(), "error: unknown command foo") {
+		t.Errorf("expected unknown commands to be reported:\n%s", out.String())
+	}
+}
+
+func TestConsoleReload(t *testing.T) {
+	s := NewServer()
+	s.commands = make(chan command, 10)
+	s.Configure(config.Default())
+	s.SetReload(func() (config.Config, error) {
+		cfg := config.Default()
+		cfg.Listen = "127.0.0.1:9999"
+		cfg.IdleTimeout = config.Duration(time.Minute)
+		cfg.Rules.TurnTimeout = config.Duration(20 * time.Second)
+		return cfg, nil
+	})
+	var out bytes.Buffer
+	s.Console(strings.NewReader("reload-config\n"), &out)
+
+	if s.idleTimeout != time.Minute || s.rules.TurnTimeout.Duration() != 20*time.Second {
+		t.Errorf("expected the runtime settings to be applied: %v %v", s.idleTimeout, s.rules.TurnTimeout)
+	}
+	if s.config.Listen != config.Default().Listen || !strings.Contains(out.String(), "listen changed") {
+		t.Errorf("expected the listen address to need a restart:\n%s", out.String())
+	}
+	if cmd := <-s.commands; cmd.id != CMD_RELOAD {
+		t.Errorf("expected the main room to be reconfigured, got %v", cmd)
+	}
+}
+
+// TestConsoleSeed seeds the shuffling twice, before two deals that have to be
+// the same.
+func TestConsoleSeed(t *testing.T) {
+	s, addr := testServer(t)
+	alice, bob, carol := dialTest(t, addr, "alice"), dialTest(t, addr, "bob"), dialTest(t, addr, "carol")
+	var deals []string
+	for i := 0; i < 2; i++ {
+		var out bytes.Buffer
+		if err := s.console(&out, "seed 42"); err != nil {
+			t.Fatal(err)
+		}
+		for j, c := range []*testClient{alice, bob, carol} {
+			c.send("/ready")
+			c.expect(fmt.Sprintf("you are ready for the game at seat %d", j+1))
+		}
+		deal := alice.expect("the landlord takes the cards").Content
+		for _, c := range []*testClient{alice, bob, carol} {
+			deal += "\n" + c.expect("_[").Content
+		}
+		deals = append(deals, deal)
+
+		if err := s.console(&out, "endgame "+MAIN_ROOM); err != nil {
+			t.Fatal(err)
+		}
+		alice.expect("> type /ready to start a new game")
+	}
+	if deals[0] != deals[1] {
+		t.Errorf("expected the same deal after the same seed:\n%s\n\n%s", deals[0], deals[1])
+	}
+}
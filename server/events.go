@@ -0,0 +1,198 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"landlord/server/util"
+)
+
+// apply applies the action of c to the game of the room and tells the
+// members what happened.
+func (r *room) apply(c *client, action util.Action) {
+	events, err := r.game.Apply(action)
+	if err != nil {
+		r.s.metrics.invalidPlay(invalidPlayReason(err))
+		c.err(err)
+		return
+	}
+	if err := r.game.CheckCards(); err != nil {
+		// a card was lost or doubled, the game can't go on
+		log.Printf("the cards of the game in %s are wrong after %#v: %s", r.name, action, err)
+		r.broadcast(MSG_MESSAGE, nil, "> the cards of the game got mixed up, the game is ended")
+		r.abandonMatch("the cards of the game got mixed up")
+		events = r.game.Abort(-1)
+		if len(events) == 0 {
+			// the action ended the game already
+			events = []util.Event{util.GameEnded{Winner: -1, Left: -1}}
+		}
+	}
+	r.handle(events)
+}
+
+// handle turns the events of the game into messages for the members.
+func (r *room) handle(events []util.Event) {
+	g := r.game
+	for _, event := range events {
+		switch e := event.(type) {
+		case util.TurnStarted:
+			r.turnStarted(e)
+		case util.BidMade:
+			if e.Points == 0 {
+				r.tell(g.Order[e.Seat], "> you passed the bid", "> %s passed the bid")
+			} else {
+				r.tell(g.Order[e.Seat], fmt.Sprintf("> you bid %d", e.Points), "> %s bid "+strconv.Itoa(e.Points))
+			}
+		case util.LandlordChosen:
+			landlord := g.Order[e.Seat]
+			r.tell(landlord, "> you are the landlord", "> %s is the landlord")
+			if e.Bid > 0 {
+				r.broadcast(MSG_MESSAGE, nil, fmt.Sprintf("> the game is played for %d points", e.Bid))
+			}
+			r.broadcast(MSG_MESSAGE, nil, fmt.Sprintf("> the landlord takes the cards [%s]", util.FormatCards(e.Cards)))
+			for _, player := range g.Order {
+				if c := r.client(player); c != nil {
+					r.viewCards(c, nil)
+				}
+			}
+		case util.CardsPlayed:
+			if e.Bomb {
+				r.s.metrics.bombPlayed()
+			}
+			player := g.Order[e.Seat]
+			if c := r.client(player); c != nil {
+				c.msg(MSG_MESSAGE, fmt.Sprintf("> you used the cards: [%s]", util.FormatCards(e.Cards)))
+				r.viewCards(c, nil)
+			}
+			r.broadcast(MSG_MESSAGE, r.client(player), fmt.Sprintf("> %s used the cards: [%s] (%v remaining)", player.Nick, util.FormatCards(e.Cards), e.Remaining))
+		case util.Passed:
+			r.tell(g.Order[e.Seat], "> you passed your turn", "> %s passed their turn")
+		case util.TrickWon:
+			if len(g.Order) > 1 {
+				r.tell(g.Order[e.Seat], "> nobody beat your cards, you lead", "> nobody beat the cards of %s, who leads")
+			}
+		case util.GameEnded:
+			r.stopTimer()
+			if e.Aborted() && e.Left >= 0 && r.table != nil {
+				r.forfeit([]string{g.Order[e.Left].Nick})
+			}
+			if !e.Aborted() {
+				r.s.metrics.gameCompleted(time.Since(g.StartedAt), g.Plays)
+				r.broadcast(MSG_MESSAGE, nil, fmt.Sprintf("> %s won the game", g.Order[e.Winner].Nick))
+				r.finishGame(e.Result)
+			}
+			r.signalEnded()
+		}
+	}
+}
+
+func (r *room) turnStarted(e util.TurnStarted) {
+	g := r.game
+	player := g.Order[e.Seat]
+	r.sendInfo()
+	c := r.client(player)
+	if c != nil {
+		c.msg(MSG_INFO, "> it's your turn")
+		switch {
+		case e.Bidding && e.Bid == 0:
+			c.msg(MSG_INFO, "  bid for becoming the landlord with /bid 1, 2 or 3, or pass with /bid 0")
+		case e.Bidding:
+			c.msg(MSG_INFO, fmt.Sprintf("  the highest bid is %d, bid up to 3 with /bid <points> or pass with /bid 0", e.Bid))
+		case len(e.Last) > 0:
+			c.msg(MSG_INFO, fmt.Sprintf("  you have to beat [%s] from %v", util.FormatCards(e.Last), g.Order[e.LastSeat].Nick))
+		default:
+			c.msg(MSG_INFO, "  you can play any cards")
+		}
+		r.viewCards(c, nil)
+		if !e.Bidding {
+			if recommend := player.Recommend(e.Last); len(recommend) == 0 {
+				c.msg(MSG_INFO, "> you can't beat the last player")
+			} else {
+				c.msg(MSG_INFO, fmt.Sprintf("> recommend: [%s]", util.FormatCards(recommend)))
+			}
+		}
+	}
+	r.broadcast(MSG_INFO, c, fmt.Sprintf("> waiting for %s's action...", player.Nick))
+	r.startTimer()
+}
+
+// tell sends self to player and others, formatted with the nickname of
+// player, to the other members.
+func (r *room) tell(player *util.Player, self, others string) {
+	c := r.client(player)
+	if c != nil {
+		c.msg(MSG_MESSAGE, self)
+	}
+	r.broadcast(MSG_MESSAGE, c, fmt.Sprintf(others, player.Nick))
+}
+
+// startTimer starts the turn timer of the current player, if the rules have
+// one. Starting a turn invalidates the timer of the turn before.
+func (r *room) startTimer() {
+	r.stopTimer()
+	timeout := r.rules.TurnTimeout.Duration()
+	if timeout <= 0 {
+		return
+	}
+	turn := r.turns
+	r.timer = time.AfterFunc(timeout, func() {
+		r.s.commands <- command{CMD_TURN_TIMEOUT, nil, []string{r.name, strconv.Itoa(turn)}}
+	})
+}
+
+func (r *room) stopTimer() {
+	r.turns++
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+}
+
+// timeout passes the turn on behalf of the current player, or plays the
+// lowest card of the player who leads, unless the turn is over already.
+func (r *room) timeout(turn int) {
+	g := r.game
+	if turn != r.turns || g.State != util.STATE_PLAYING || g.CurrentPlayer == nil {
+		return
+	}
+	player := g.CurrentPlayer
+	if c := r.client(player); c != nil {
+		c.msg(MSG_INFO, "> time is up")
+	}
+	var action util.Action = util.Pass{Seat: player.Seat}
+	if g.Bidding {
+		action = util.Bid{Seat: player.Seat, Points: 0}
+	} else if len(g.LastUsedCards) == 0 {
+		// the leader can't pass and plays the lowest card
+		lowest := *player.Cards[len(player.Cards)-1]
+		action = util.Play{Seat: player.Seat, Cards: []*util.Card{&lowest}}
+	}
+	if events, err := g.Apply(action); err == nil {
+		r.handle(events)
+	}
+}
+
+// signalEnded wakes up the loop of the room waiting for the game to end.
+func (r *room) signalEnded() {
+	select {
+	case r.ended <- struct{}{}:
+	default:
+	}
+}
+
+// client is the member playing as player, if it's still in the room.
+func (r *room) client(player *util.Player) *client {
+	if c, ok := r.members.Load(player.ID); ok {
+		return c.(*client)
+	}
+	return nil
+}
+
+func (r *room) seatOf(c *client) int {
+	if player, ok := r.game.Player(c.ID); ok {
+		return player.Seat
+	}
+	return -1
+}
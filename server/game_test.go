@@ -0,0 +1,183 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"landlord/config"
+)
+
+// landlordDeck deals the first seat a hand that wins the game in four plays,
+// while the farmers can't beat anything but the pair.
+func landlordDeck(t *testing.T) func(*server) {
+	deck := stackDeck(t, 3, "JOKER 4 4", "3 4 5 6 7 8 9 10 J Q K A 2 2 2 2 joker")
+	return func(s *server) { s.deck = &deck }
+}
+
+// pass passes the turn of every client in turn.
+func pass(clients ...*testClient) {
+	for _, c := range clients {
+		c.expect("it's your turn")
+		c.send("/pass")
+		c.expect("you passed your turn")
+	}
+}
+
+// win plays the hand of landlordDeck, with which the landlord wins before the
+// farmers play. The landlord must have been chosen.
+func win(landlord *testClient, farmers ...*testClient) {
+	for _, play := range []string{"3 4 5 6 7 8 9 10 J Q K A", "4 4", "2 2 2 2"} {
+		landlord.send("/use " + play)
+		landlord.expect("> you used the cards")
+		for _, c := range farmers {
+			c.expect("> " + landlord.nick + " used the cards")
+		}
+		pass(farmers...)
+		landlord.expect("nobody beat your cards, you lead")
+	}
+	landlord.send("/use joker JOKER")
+	for _, c := range append(farmers, landlord) {
+		c.expect(landlord.nick + " won the game")
+	}
+}
+
+func TestScriptedGame(t *testing.T) {
+	s, addr := testServer(t)
+	landlordDeck(t)(s)
+	alice, bob, carol := dialTest(t, addr, "alice"), dialTest(t, addr, "bob"), dialTest(t, addr, "carol")
+	seatAll(alice, bob, carol)
+
+	alice.expect("it's your turn")
+	alice.expect("you can play any cards")
+	win(alice, bob, carol)
+	scores := carol.expect("> scores")
+	if scores.Content != "> scores (x8, spring):\n   alice: +16\n   bob: -8\n   carol: -8" {
+		t.Errorf("unexpected scores:\n%s", scores.Content)
+	}
+	if !bob.saw("> alice used the cards: [♦2 ♥2 ♣2 ♠2] (2 remaining)") {
+		t.Errorf("expected the bomb to be shown to the others:\n%s", bob.transcript())
+	}
+
+	// the game is thrown away once the next one is set up
+	alice.send("/ready")
+	alice.expect("> the game just ended, type /ready again once the next game is set up")
+	alice.expect("> type /ready to start a new game")
+	alice.send("/ready")
+	alice.expect("> you are ready for the game at seat 1")
+
+	bob.send("/quit")
+	bob.expect("> see you next time")
+	bob.expectClosed()
+	alice.expect("> bob left the room")
+	carol.expect("> bob left the room")
+}
+
+func TestCardsMixedUp(t *testing.T) {
+	s, addr := testServer(t)
+	landlordDeck(t)(s)
+	alice, bob, carol := dialTest(t, addr, "alice"), dialTest(t, addr, "bob"), dialTest(t, addr, "carol")
+	seatAll(alice, bob, carol)
+	alice.expect("it's your turn")
+
+	s.mu.Lock()
+	g := s.main.game
+	g.Order[1].Cards = append(g.Order[1].Cards, g.Order[0].Cards[0])
+	s.mu.Unlock()
+	alice.send("/use 3")
+	for _, c := range []*testClient{alice, bob, carol} {
+		c.expect("> the cards of the game got mixed up, the game is ended")
+	}
+	if bob.saw("it's your turn") {
+		t.Errorf("expected the game to end before the turn of bob:\n%s", bob.transcript())
+	}
+}
+
+func TestInvalidPlays(t *testing.T) {
+	s, addr := testServer(t)
+	landlordDeck(t)(s)
+	alice, bob, carol := dialTest(t, addr, "alice"), dialTest(t, addr, "bob"), dialTest(t, addr, "carol")
+	seatAll(alice, bob, carol)
+	alice.expect("it's your turn")
+
+	bob.send("/use 5")
+	bob.expect("> it's not your turn")
+	alice.send("/use 5 5")
+	alice.expect("> you don't have the cards")
+	// alice has the ♠3 only
+	alice.send("/use ♦3")
+	alice.expect("> you don't have the cards")
+	alice.send("/use 3 5")
+	alice.expect("> invalid cards")
+	alice.send("/use 3 X")
+	alice.expect(`> unknown card "X"`)
+	alice.send("/pass")
+	alice.expect("> you must play, you lead the trick")
+
+	alice.send("/quit")
+	alice.expectClosed()
+	bob.expect("> alice left the room, game ends")
+	carol.expect("> alice left the room, game ends")
+	bob.send("/use 5")
+	bob.expect("> you must first join a game")
+
+	s.metrics.mu.Lock()
+	defer s.metrics.mu.Unlock()
+	if s.metrics.gamesCompleted != 0 || s.metrics.disconnectsMidGame != 1 {
+		t.Errorf("expected an abandoned game, got %d completed and %d disconnects",
+			s.metrics.gamesCompleted, s.metrics.disconnectsMidGame)
+	}
+}
+
+func TestScriptedBidding(t *testing.T) {
+	s, addr := testServer(t, func(cfg *config.Config) { cfg.Rules.Bidding = true })
+	landlordDeck(t)(s)
+	alice, bob, carol := dialTest(t, addr, "alice"), dialTest(t, addr, "bob"), dialTest(t, addr, "carol")
+	for i, c := range []*testClient{alice, bob, carol} {
+		c.send("/ready")
+		c.expect("you are ready for the game at seat " + string(rune('1'+i)))
+	}
+
+	alice.expect("bid for becoming the landlord")
+	alice.send("/use 3")
+	alice.expect("> the landlord is still being chosen")
+	alice.send("/bid 1")
+	bob.expect("the highest bid is 1")
+	bob.send("/bid 1")
+	bob.expect("> the bid must be higher than the current bid")
+	bob.send("/bid")
+	bob.expect("> usage: /bid <points>")
+	bob.send("/bid x")
+	bob.expect("> usage: /bid <points>")
+	bob.send("/bid 0")
+	carol.expect("the highest bid is 1")
+	carol.send("/bid 3")
+	expectLandlord(carol, alice, bob, carol)
+	for _, c := range []*testClient{alice, bob, carol} {
+		c.expect("> the game is played for 3 points")
+	}
+	carol.expect("it's your turn")
+	if !carol.saw("> the landlord takes the cards [JOKER ♠4 ♣4]") {
+		t.Errorf("expected the bottom cards to go to carol:\n%s", carol.transcript())
+	}
+
+	s.metrics.mu.Lock()
+	defer s.metrics.mu.Unlock()
+	if n := s.metrics.invalidPlays[REASON_BID]; n != 4 {
+		t.Errorf("expected 4 invalid plays for bidding, got %d", n)
+	}
+}
+
+func TestTurnTimeout(t *testing.T) {
+	s, addr := testServer(t, func(cfg *config.Config) {
+		cfg.Rules.TurnTimeout = config.Duration(200 * time.Millisecond)
+	})
+	landlordDeck(t)(s)
+	alice, bob, carol := dialTest(t, addr, "alice"), dialTest(t, addr, "bob"), dialTest(t, addr, "carol")
+	seatAll(alice, bob, carol)
+
+	// the leader can't pass
+	alice.expect("> time is up")
+	alice.expect("> you used the cards: [♠3]")
+	bob.expect("> time is up")
+	bob.expect("> you passed your turn")
+}
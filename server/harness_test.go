@@ -0,0 +1,271 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"landlord/config"
+	"landlord/server/util"
+)
+
+// The tests play against a server running in the test on a loopback port.
+// Scripted clients take turns from the test goroutine, waiting for the
+// messages they expect, and every message they received is kept for the
+// assertions.
+
+// EXPECT_TIMEOUT is the time a test client waits for an expected message
+const EXPECT_TIMEOUT = 5 * time.Second
+
+// testServer runs a server on a local port, with its data in a temporary
+// directory. configure changes the default config, if given.
+func testServer(t *testing.T, configure ...func(*config.Config)) (*server, string) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.DataDir = t.TempDir()
+	// the scripted clients answer faster than people
+	cfg.Limits.CommandRate = 0
+	cfg.Limits.ChatRate = 0
+	for _, f := range configure {
+		f(&cfg)
+	}
+	s := NewServer()
+	s.Configure(cfg)
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listen.Close() })
+	go func() {
+		for {
+			conn, err := listen.Accept()
+			if err != nil {
+				return
+			}
+			go s.NewClient(conn)
+		}
+	}()
+	go s.RunCommands()
+	go s.GameLoop()
+	t.Cleanup(func() { close(s.main.done) })
+	return s, listen.Addr().String()
+}
+
+type testClient struct {
+	t        *testing.T
+	nick     string
+	conn     net.Conn
+	messages chan Message
+	// received are the messages taken from messages so far
+	received []Message
+}
+
+func dialTest(t *testing.T, addr, nick string) *testClient {
+	t.Helper()
+	return dialAs(t, addr, nick, nick)
+}
+
+// dialAccount registers an account for nick and logs in with it.
+func dialAccount(t *testing.T, addr, nick string) *testClient {
+	t.Helper()
+	return dialAs(t, addr, nick, "/register "+nick+" password")
+}
+
+func dialAs(t *testing.T, addr, nick, handshake string) *testClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	fmt.Fprintln(conn, handshake)
+	reader := bufio.NewReader(conn)
+	if line, err := reader.ReadString('\n'); err != nil || line != "ok\n" {
+		t.Fatalf("unable to log in as %s: %q %v", nick, line, err)
+	}
+	c := &testClient{t: t, nick: nick, conn: conn, messages: make(chan Message, OUTBOUND_QUEUE_SIZE)}
+	go func() {
+		defer close(c.messages)
+		decoder := json.NewDecoder(reader)
+		for {
+			var msg Message
+			if err := decoder.Decode(&msg); err != nil {
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+	return c
+}
+
+func (c *testClient) send(line string) {
+	fmt.Fprintln(c.conn, line)
+}
+
+// expect waits for a message containing text and returns it. It must be
+// called from the test goroutine.
+func (c *testClient) expect(text string) Message {
+	c.t.Helper()
+	timeout := time.After(EXPECT_TIMEOUT)
+	for {
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				c.t.Fatalf("%s was disconnected while waiting for %q", c.nick, text)
+			}
+			c.received = append(c.received, msg)
+			if strings.Contains(msg.Content, text) {
+				return msg
+			}
+		case <-timeout:
+			c.t.Fatalf("%s didn't receive %q, got:\n%s", c.nick, text, c.transcript())
+		}
+	}
+}
+
+// expectClosed waits for the server to close the connection.
+func (c *testClient) expectClosed() {
+	c.t.Helper()
+	timeout := time.After(EXPECT_TIMEOUT)
+	for {
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				return
+			}
+			c.received = append(c.received, msg)
+		case <-timeout:
+			c.t.Fatalf("expected the connection of %s to be closed", c.nick)
+		}
+	}
+}
+
+// saw reports whether a message containing text was received so far.
+func (c *testClient) saw(text string) bool {
+	for _, msg := range c.received {
+		if strings.Contains(msg.Content, text) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *testClient) transcript() string {
+	var lines []string
+	for _, msg := range c.received {
+		lines = append(lines, fmt.Sprintf("  %d %s", msg.MsgType, msg.Content))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// play plays the recommended cards until the game is won. Unlike the other
+// methods, it may be called from any goroutine.
+func (c *testClient) play(timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				return fmt.Errorf("%s was disconnected", c.nick)
+			}
+			content := msg.Content
+			switch {
+			case strings.Contains(content, "won the game"):
+				return nil
+			case strings.Contains(content, "game ends"):
+				return fmt.Errorf("%s: %s", c.nick, content)
+			case strings.Contains(content, "> recommend: ["):
+				c.send("/use " + content[strings.Index(content, "[")+1:strings.LastIndex(content, "]")])
+			case strings.Contains(content, "can't beat the last player"):
+				c.send("/pass")
+			}
+		case <-deadline:
+			return fmt.Errorf("%s: the game didn't end in time", c.nick)
+		}
+	}
+}
+
+// seatAll makes the clients ready in turn, so that they take the seats in
+// that order, and waits for the game to start. The deck of the server must
+// be stacked, so that the first seat is the landlord.
+func seatAll(clients ...*testClient) {
+	for i, c := range clients {
+		c.send("/ready")
+		c.expect(fmt.Sprintf("you are ready for the game at seat %d", i+1))
+	}
+	expectLandlord(clients[0], clients...)
+}
+
+// expectLandlord waits for every client to be told that landlord is the
+// landlord.
+func expectLandlord(landlord *testClient, clients ...*testClient) {
+	for _, c := range clients {
+		if c == landlord {
+			c.expect("> you are the landlord")
+		} else {
+			c.expect("> " + landlord.nick + " is the landlord")
+		}
+	}
+}
+
+// stackDeck returns a deck dealing hands to the first seats of a game of
+// players, and bottom to the landlord. Cards are given as for /use, those
+// not given are dealt in the order of a new deck.
+func stackDeck(t *testing.T, players int, bottom string, hands ...string) util.Deck {
+	t.Helper()
+	fresh := util.NewDeck()
+	pool := append([]util.Card(nil), fresh.Cards[:]...)
+	take := func(want util.Card) util.Card {
+		for i, card := range pool {
+			if want.Matches(card) {
+				pool = append(pool[:i:i], pool[i+1:]...)
+				return card
+			}
+		}
+		t.Fatalf("no %s left in the deck", want)
+		return util.Card{}
+	}
+
+	var wanted [][]util.Card
+	for _, hand := range append([]string{bottom}, hands...) {
+		parsed, err := util.ParseCards(hand)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cards []util.Card
+		for _, card := range parsed {
+			cards = append(cards, take(card))
+		}
+		wanted = append(wanted, cards)
+	}
+	fill := func(cards []util.Card, n int) []util.Card {
+		for len(cards) < n {
+			cards = append(cards, pool[0])
+			pool = pool[1:]
+		}
+		return cards
+	}
+
+	var dealt []util.Card
+	for seat := 0; seat < players; seat++ {
+		var hand []util.Card
+		if seat+1 < len(wanted) {
+			hand = wanted[seat+1]
+		}
+		dealt = append(dealt, fill(hand, 17)...)
+	}
+	dealt = append(dealt, fill(wanted[0], 3)...)
+	dealt = fill(dealt, util.NUM_CARDS)
+	// the deck deals from its end
+	for i, card := range dealt {
+		fresh.Cards[util.NUM_CARDS-1-i] = card
+	}
+	return fresh
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// violation kinds reported by landlord_violations_total
+const (
+	VIOLATION_BANNED      = "banned"
+	VIOLATION_CONNECTIONS = "connections"
+	VIOLATION_COMMANDS    = "commands"
+	VIOLATION_CHAT        = "chat"
+	VIOLATION_SLOW        = "slow"
+)
+
+// VIOLATION_HISTORY is the number of recent violations kept for the admins
+const VIOLATION_HISTORY = 100
+
+// limiter is a token bucket allowing rate events per second after a burst.
+// A nil limiter allows everything.
+type limiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	// dropped counts the events refused since the last allowed one
+	dropped int
+}
+
+func newLimiter(rate float64, burst int) *limiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &limiter{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+func (l *limiter) allow(now time.Time) bool {
+	if l == nil {
+		return true
+	}
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+	if l.tokens < 1 {
+		l.dropped++
+		return false
+	}
+	l.tokens--
+	l.dropped = 0
+	return true
+}
+
+// connections counts the open connections per IP address.
+type connections struct {
+	mu    sync.Mutex
+	count map[string]int
+}
+
+// acquire counts a connection from host, unless max connections are open
+// already. Zero allows any number of connections.
+func (c *connections) acquire(host string, max int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if max > 0 && c.count[host] >= max {
+		return false
+	}
+	c.count[host]++
+	return true
+}
+
+func (c *connections) release(host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.count[host]--; c.count[host] <= 0 {
+		delete(c.count, host)
+	}
+}
+
+type violation struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"`
+	Nick   string    `json:"nick,omitempty"`
+	Addr   string    `json:"addr"`
+	Detail string    `json:"detail"`
+}
+
+// violations are the recent violations of the limits and bans, reported to
+// the admins.
+type violations struct {
+	mu     sync.Mutex
+	recent []violation
+	counts map[string]int
+}
+
+func (v *violations) add(kind, nick string, addr net.Addr, detail string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if nick == "#anonymous" {
+		nick = ""
+	}
+	if nick != "" {
+		log.Printf("violation: %s by %s (%v): %s", kind, nick, addr, detail)
+	} else {
+		log.Printf("violation: %s from %v: %s", kind, addr, detail)
+	}
+	v.recent = append(v.recent, violation{time.Now(), kind, nick, addr.String(), detail})
+	if len(v.recent) > VIOLATION_HISTORY {
+		v.recent = v.recent[len(v.recent)-VIOLATION_HISTORY:]
+	}
+	v.counts[kind]++
+}
+
+func (v *violations) list() []violation {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append([]violation{}, v.recent...)
+}
+
+// throttle reports whether the next command of c is dropped by l. Only the
+// first dropped command of a burst is reported.
+func (c *client) throttle(l *limiter, kind string) bool {
+	if l.allow(time.Now()) {
+		return false
+	}
+	if l.dropped == 1 {
+		c.violations.add(kind, c.Nick, c.Conn.RemoteAddr(), "rate limit exceeded")
+	}
+	return true
+}
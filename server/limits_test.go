@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(2, 3)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if !l.allow(now) {
+			t.Fatalf("expected the burst to be allowed, refused command %d", i+1)
+		}
+	}
+	if l.allow(now) || l.allow(now.Add(100*time.Millisecond)) {
+		t.Fatal("expected commands beyond the burst to be refused")
+	}
+	if l.dropped != 2 {
+		t.Errorf("expected 2 dropped commands, got %d", l.dropped)
+	}
+	if !l.allow(now.Add(600 * time.Millisecond)) {
+		t.Error("expected the rate to refill the bucket")
+	}
+	if !l.allow(now.Add(time.Hour)) || !l.allow(now.Add(time.Hour)) || !l.allow(now.Add(time.Hour)) || l.allow(now.Add(time.Hour)) {
+		t.Error("expected the bucket to be capped at the burst")
+	}
+
+	unlimited := newLimiter(0, 0)
+	if !unlimited.allow(now) {
+		t.Error("expected a disabled limiter to allow everything")
+	}
+}
+
+func TestConnections(t *testing.T) {
+	c := &connections{count: make(map[string]int)}
+	if !c.acquire("10.0.0.1", 2) || !c.acquire("10.0.0.1", 2) || c.acquire("10.0.0.1", 2) {
+		t.Fatal("expected the third connection to be refused")
+	}
+	if !c.acquire("10.0.0.2", 2) {
+		t.Error("expected other addresses to be allowed")
+	}
+	c.release("10.0.0.1")
+	if !c.acquire("10.0.0.1", 2) {
+		t.Error("expected a released connection to free a slot")
+	}
+	if !c.acquire("10.0.0.1", 0) {
+		t.Error("expected no limit for zero")
+	}
+}
+
+func TestBans(t *testing.T) {
+	dir := t.TempDir()
+	b, err := loadBans(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip, err := b.add("::ffff:10.0.0.1"); err != nil || !ip {
+		t.Fatalf("expected an ip ban: %v %v", ip, err)
+	}
+	if ip, err := b.add("Alice"); err != nil || ip {
+		t.Fatalf("expected a nickname ban: %v %v", ip, err)
+	}
+
+	b, err = loadBans(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.ip(&net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000}) || !b.nick("alice") {
+		t.Fatal("expected the bans to be saved")
+	}
+	if b.ip(&net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 4000}) || b.nick("bob") {
+		t.Error("expected other players to be allowed")
+	}
+	if removed, err := b.remove("ALICE"); err != nil || !removed {
+		t.Fatalf("expected the ban to be lifted: %v %v", removed, err)
+	}
+	if b.nick("alice") || len(b.list().Nicks) != 0 {
+		t.Error("expected alice to be allowed again")
+	}
+}
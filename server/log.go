@@ -0,0 +1,16 @@
+package server
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+var debugLogging atomic.Bool
+
+// debugf logs the traffic between server and clients, which is only wanted
+// with the debug log level.
+func debugf(format string, v ...any) {
+	if debugLogging.Load() {
+		log.Printf(format, v...)
+	}
+}
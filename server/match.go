@@ -0,0 +1,212 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"landlord/server/util"
+)
+
+// match is a series of deals played by the same players, either a fixed
+// number of deals or until a player reaches the target score.
+type match struct {
+	Deals  int `json:"deals"`
+	Target int `json:"target"`
+	// Seats is the seat order, fixed by the first deal
+	Seats  []string       `json:"seats"`
+	Played int            `json:"played"`
+	Scores map[string]int `json:"scores"`
+}
+
+func (m *match) over() bool {
+	if m.Deals > 0 {
+		return m.Played >= m.Deals
+	}
+	for _, score := range m.Scores {
+		if score >= m.Target {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *match) hasSeat(nick string) bool {
+	for _, seat := range m.Seats {
+		if seat == nick {
+			return true
+		}
+	}
+	return m.Seats == nil
+}
+
+// seat puts the players of a deal in the seat order of the match. The
+// landlord rotates with every deal. If the players don't match the seats,
+// the order is kept and the landlord is picked at random.
+func (m *match) seat(players []*util.Player) ([]*util.Player, int) {
+	if m.Seats == nil {
+		for _, player := range players {
+			m.Seats = append(m.Seats, player.Nick)
+		}
+	}
+	byNick := make(map[string]*util.Player)
+	for _, player := range players {
+		byNick[player.Nick] = player
+	}
+	var seated []*util.Player
+	for _, nick := range m.Seats {
+		if player, ok := byNick[nick]; ok {
+			seated = append(seated, player)
+		}
+	}
+	if len(seated) != len(players) {
+		return players, util.R.Intn(len(players))
+	}
+	return seated, m.Played % len(seated)
+}
+
+func (m *match) record(result util.Result) {
+	m.Played++
+	for _, nick := range m.Seats {
+		m.Scores[nick] += result.Scores[nick]
+	}
+}
+
+// winners are the players with the highest score.
+func (m *match) winners() []string {
+	var winners []string
+	best := 0
+	for _, nick := range m.Seats {
+		score := m.Scores[nick]
+		if winners == nil || score > best {
+			winners, best = []string{nick}, score
+		} else if score == best {
+			winners = append(winners, nick)
+		}
+	}
+	return winners
+}
+
+func (m *match) progress() string {
+	if m.Deals > 0 {
+		return fmt.Sprintf("deal %d/%d", m.Played, m.Deals)
+	}
+	return fmt.Sprintf("deal %d, playing to %d", m.Played, m.Target)
+}
+
+func (m *match) String() string {
+	nicks := append([]string(nil), m.Seats...)
+	sort.SliceStable(nicks, func(i, j int) bool {
+		return m.Scores[nicks[i]] > m.Scores[nicks[j]]
+	})
+	msg := "> match scores after " + m.progress() + ":"
+	for _, nick := range nicks {
+		msg += fmt.Sprintf("\n   %-16s %+d", nick, m.Scores[nick])
+	}
+	return msg
+}
+
+// matchCommand handles
+//
+//	/match: show the running match
+//	/match <deals>: play a number of deals
+//	/match to <score>: play until a player reaches the score
+//	/match off: cancel the match before it starts
+func (s *server) matchCommand(c *client, args []string) {
+	r := c.room
+	if len(args) < 2 {
+		if r.match == nil {
+			c.err(errors.New("> no match in this room, type /match <deals> or /match to <score> to set one up"))
+			return
+		}
+		if r.match.Seats == nil {
+			c.msg(MSG_MESSAGE, "> the match starts with the next game, "+r.match.progress())
+			return
+		}
+		c.msg(MSG_MESSAGE, r.match.String())
+		return
+	}
+	if r.table != nil || r.matched {
+		c.err(errors.New("> the games of this room can't be played as a match"))
+		return
+	}
+	if r.match != nil && r.match.Seats != nil {
+		c.err(errors.New("> a match is in progress"))
+		return
+	}
+	if r.game.State != util.STATE_WAITING {
+		c.err(errors.New("> wait for the game to end first"))
+		return
+	}
+
+	m := &match{Scores: make(map[string]int)}
+	var err error
+	switch {
+	case args[1] == "off":
+		if r.match == nil {
+			c.err(errors.New("> no match in this room"))
+			return
+		}
+		r.match = nil
+		r.broadcast(MSG_MESSAGE, nil, fmt.Sprintf("> %s cancelled the match", c.Nick))
+		return
+	case args[1] == "to" && len(args) == 3:
+		m.Target, err = strconv.Atoi(args[2])
+		if err == nil && m.Target <= 0 {
+			err = errors.New("the target score must be positive")
+		}
+	default:
+		m.Deals, err = strconv.Atoi(args[1])
+		if err == nil && m.Deals <= 0 {
+			err = errors.New("the number of deals must be positive")
+		}
+	}
+	if err != nil {
+		c.err(errors.New("> usage: /match <deals> | /match to <score> | /match off"))
+		return
+	}
+	r.match = m
+	if m.Deals > 0 {
+		r.broadcast(MSG_MESSAGE, nil, fmt.Sprintf("> %s set up a match of %d deals, type /ready to play", c.Nick, m.Deals))
+	} else {
+		r.broadcast(MSG_MESSAGE, nil, fmt.Sprintf("> %s set up a match to %d points, type /ready to play", c.Nick, m.Target))
+	}
+}
+
+// nextDeal seats the players of the match for its next deal. It returns
+// false once the match is over, after announcing the winner.
+func (r *room) nextDeal() bool {
+	m := r.match
+	if !m.over() {
+		r.game = util.NewGame()
+		r.game.NumPlayers = r.rules.NumPlayers
+		r.broadcast(MSG_MESSAGE, nil, fmt.Sprintf("> next deal of the match: %d", m.Played+1))
+		r.members.Range(func(_, c any) bool {
+			if m.hasSeat(c.(*client).Nick) {
+				r.seat(c.(*client))
+			}
+			return true
+		})
+		r.sendInfo()
+		return true
+	}
+	winners := m.winners()
+	msg := fmt.Sprintf("> %s won the match with %+d", strings.Join(winners, " and "), m.Scores[winners[0]])
+	if len(winners) > 1 {
+		msg = fmt.Sprintf("> the match is a draw between %s with %+d", strings.Join(winners, " and "), m.Scores[winners[0]])
+	}
+	r.broadcast(MSG_MESSAGE, nil, msg)
+	r.match = nil
+	return false
+}
+
+// abandonMatch ends the match after a deal that couldn't be finished.
+func (r *room) abandonMatch(reason string) {
+	if r.match == nil || r.match.Seats == nil {
+		return
+	}
+	r.match = nil
+	r.broadcast(MSG_MESSAGE, nil, "> the match was abandoned, "+reason)
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"testing"
+
+	"landlord/server/util"
+)
+
+func TestMatch(t *testing.T) {
+	m := &match{Deals: 3, Scores: make(map[string]int)}
+	players := []*util.Player{{Nick: "alice"}, {Nick: "bob"}, {Nick: "carol"}}
+	for deal := 0; deal < 3; deal++ {
+		if m.over() {
+			t.Fatalf("match over after %d deals", deal)
+		}
+		// the players come in any order, but keep their seats
+		shuffled := []*util.Player{players[(deal+1)%3], players[(deal+2)%3], players[deal%3]}
+		if deal == 0 {
+			shuffled = players
+		}
+		seated, landlord := m.seat(shuffled)
+		for i, player := range seated {
+			if player != players[i] {
+				t.Errorf("deal %d: seat %d taken by %s", deal, i, player.Nick)
+			}
+		}
+		if landlord != deal {
+			t.Errorf("deal %d: expected the landlord to rotate, got seat %d", deal, landlord)
+		}
+		m.record(util.Result{Scores: map[string]int{"alice": 2, "bob": -1, "carol": -1}})
+	}
+	if !m.over() {
+		t.Error("expected the match to be over after 3 deals")
+	}
+	if winners := m.winners(); len(winners) != 1 || winners[0] != "alice" || m.Scores["alice"] != 6 {
+		t.Errorf("unexpected winners %v with %v", winners, m.Scores)
+	}
+	if !m.hasSeat("bob") || m.hasSeat("dave") {
+		t.Error("expected only the players of the match to have a seat")
+	}
+
+	m = &match{Target: 4, Seats: []string{"alice", "bob"}, Scores: map[string]int{"alice": 2, "bob": 2}}
+	if m.over() {
+		t.Error("match to 4 points over too early")
+	}
+	if winners := m.winners(); len(winners) != 2 {
+		t.Errorf("expected a draw, got %v", winners)
+	}
+	m.record(util.Result{Scores: map[string]int{"alice": -2, "bob": 2}})
+	if !m.over() || m.winners()[0] != "bob" {
+		t.Errorf("expected bob to win the match, got %v", m.Scores)
+	}
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"landlord/server/util"
+)
+
+// invalid play reasons reported by landlord_invalid_plays_total
+const (
+	REASON_NOT_IN_GAME  = "not_in_game"
+	REASON_NOT_TURN     = "not_turn"
+	REASON_UNKNOWN_CARD = "unknown_card"
+	REASON_EMPTY        = "empty"
+	REASON_INVALID      = "invalid"
+	REASON_NOT_OWNED    = "not_owned"
+	REASON_CANNOT_BEAT  = "cannot_beat"
+	REASON_BID          = "bid"
+)
+
+type metrics struct {
+	mu                 sync.Mutex
+	gamesCompleted     int
+	gameSeconds        float64
+	plays              int
+	bombsPlayed        int
+	invalidPlays       map[string]int
+	disconnectsMidGame int
+	sends              int
+	sendSeconds        float64
+}
+
+func newMetrics() *metrics {
+	return &metrics{invalidPlays: make(map[string]int)}
+}
+
+func (m *metrics) gameCompleted(duration time.Duration, plays int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gamesCompleted++
+	m.gameSeconds += duration.Seconds()
+	m.plays += plays
+}
+
+func (m *metrics) bombPlayed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bombsPlayed++
+}
+
+func (m *metrics) invalidPlay(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.invalidPlays[reason]++
+}
+
+func (m *metrics) disconnectedMidGame() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.disconnectsMidGame++
+}
+
+func (m *metrics) messageSent(latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sends++
+	m.sendSeconds += latency.Seconds()
+}
+
+func invalidPlayReason(err error) string {
+	switch err {
+	case util.ErrNotTurn:
+		return REASON_NOT_TURN
+	case util.ErrNotOwned:
+		return REASON_NOT_OWNED
+	case util.ErrCannotBeat:
+		return REASON_CANNOT_BEAT
+	case util.ErrInvalidBid, util.ErrNotBidding, util.ErrBidding:
+		return REASON_BID
+	default:
+		return REASON_INVALID
+	}
+}
+
+func (s *server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	s.writeMetrics(w)
+}
+
+// writeMetrics writes all metrics in the prometheus text exposition format.
+func (s *server) writeMetrics(w io.Writer) {
+	connected := lenSyncMap(&s.members)
+	activeGames := 0
+	s.mu.Lock()
+	s.rooms.Range(func(_, r any) bool {
+		if r.(*room).game.State == util.STATE_PLAYING {
+			activeGames++
+		}
+		return true
+	})
+	s.mu.Unlock()
+
+	m := s.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	gauge(w, "landlord_connected_clients", "Number of connected clients.", connected)
+	gauge(w, "landlord_active_games", "Number of games in progress.", activeGames)
+	counter(w, "landlord_games_completed_total", "Number of games played to the end.", m.gamesCompleted)
+	summary(w, "landlord_game_duration_seconds", "Duration of completed games.", m.gameSeconds, m.gamesCompleted)
+	summary(w, "landlord_game_plays", "Number of plays in completed games.", float64(m.plays), m.gamesCompleted)
+	counter(w, "landlord_bombs_played_total", "Number of bombs and rockets played.", m.bombsPlayed)
+
+	fmt.Fprintln(w, "# HELP landlord_invalid_plays_total Number of rejected plays by reason.")
+	fmt.Fprintln(w, "# TYPE landlord_invalid_plays_total counter")
+	var reasons []string
+	for reason := range m.invalidPlays {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(w, "landlord_invalid_plays_total{reason=%q} %d\n", reason, m.invalidPlays[reason])
+	}
+
+	counter(w, "landlord_disconnects_mid_game_total", "Number of players leaving a game in progress.", m.disconnectsMidGame)
+	summary(w, "landlord_message_send_seconds", "Latency of sending a message to a client.", m.sendSeconds, m.sends)
+
+	v := s.violations
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	fmt.Fprintln(w, "# HELP landlord_violations_total Number of violations of the bans and limits by kind.")
+	fmt.Fprintln(w, "# TYPE landlord_violations_total counter")
+	var kinds []string
+	for kind := range v.counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Fprintf(w, "landlord_violations_total{kind=%q} %d\n", kind, v.counts[kind])
+	}
+}
+
+func gauge(w io.Writer, name, help string, value int) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, value)
+}
+
+func counter(w io.Writer, name, help string, value int) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, value)
+}
+
+func summary(w io.Writer, name, help string, sum float64, count int) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s summary\n%s_sum %g\n%s_count %d\n", name, help, name, name, sum, name, count)
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"landlord/config"
+	"landlord/server/util"
+)
+
+// roomCommand shows the settings of the room, which only the owner of a
+// created room can change:
+//
+//	/room kick <nickname>
+//	/room lock|unlock
+//	/room owner <nickname>
+//	/room seats <number of players>
+//	/room timer <duration>|off
+//	/room bidding on|off
+func (s *server) roomCommand(c *client, args []string) {
+	r := c.room
+	if len(args) < 2 {
+		c.msg(MSG_MESSAGE, r.settings())
+		return
+	}
+	if r.owner == nil {
+		c.err(errors.New("> this room has no owner"))
+		return
+	}
+	if r.owner != c {
+		c.err(fmt.Errorf("> only the owner of the room can change it, ask %s", r.owner.Nick))
+		return
+	}
+
+	switch {
+	case args[1] == "kick" && len(args) == 3:
+		target := r.member(args[2])
+		if target == nil || target == c {
+			c.err(fmt.Errorf("> no other member called %s", args[2]))
+			return
+		}
+		target.msg(MSG_MESSAGE, "> you have been kicked from the room by "+c.Nick)
+		s.enter(target, s.main)
+		r.broadcast(MSG_MESSAGE, nil, fmt.Sprintf("> %s was kicked by %s", target.Nick, c.Nick))
+	case args[1] == "lock" || args[1] == "unlock":
+		r.locked = args[1] == "lock"
+		r.broadcast(MSG_MESSAGE, nil, fmt.Sprintf("> %s %sed the room", c.Nick, args[1]))
+	case args[1] == "owner" && len(args) == 3:
+		target := r.member(args[2])
+		if target == nil {
+			c.err(fmt.Errorf("> no member called %s", args[2]))
+			return
+		}
+		r.owner = target
+		r.broadcast(MSG_MESSAGE, nil, fmt.Sprintf("> %s is now the owner of the room", target.Nick))
+	case (args[1] == "seats" || args[1] == "timer" || args[1] == "bidding") && len(args) == 3:
+		if r.game.State != util.STATE_WAITING || r.resume != nil || (r.match != nil && r.match.Seats != nil) {
+			c.err(errors.New("> the rules can only be changed before a game starts"))
+			return
+		}
+		rules := r.rules
+		if args[1] == "seats" {
+			n, err := strconv.Atoi(args[2])
+			if err != nil {
+				c.err(errors.New("> usage: /room seats <number of players>"))
+				return
+			}
+			rules.NumPlayers = n
+		} else if args[1] == "bidding" {
+			if args[2] != "on" && args[2] != "off" {
+				c.err(errors.New("> usage: /room bidding on|off"))
+				return
+			}
+			rules.Bidding = args[2] == "on"
+		} else if args[2] == "off" {
+			rules.TurnTimeout = 0
+		} else {
+			d, err := time.ParseDuration(args[2])
+			if err != nil {
+				c.err(errors.New("> usage: /room timer <duration>|off, e.g. /room timer 30s"))
+				return
+			}
+			rules.TurnTimeout = config.Duration(d)
+		}
+		if err := rules.Validate(); err != nil {
+			c.err(errors.New("> invalid rules: " + err.Error()))
+			return
+		}
+		s.setRules(r, rules)
+		r.broadcast(MSG_MESSAGE, nil, fmt.Sprintf("> %s changed the rules:\n%s", c.Nick, r.rulesString()))
+	default:
+		c.err(errors.New("> usage: /room [kick <nickname>|lock|unlock|owner <nickname>|seats <number>|timer <duration>|off|bidding on|off]"))
+	}
+}
+
+// setRules applies rules to the next game of r. Players who are ready have
+// to get ready again if the number of players changes.
+func (s *server) setRules(r *room, rules config.Rules) {
+	changed := rules.NumPlayers != r.rules.NumPlayers
+	r.rules = rules
+	if changed {
+		if r.game.NumReady() > 0 {
+			r.broadcast(MSG_MESSAGE, nil, "> the number of players changed, type /ready to play")
+		}
+		r.game = util.NewGame()
+		r.game.NumPlayers = rules.NumPlayers
+		r.sendInfo()
+	}
+}
+
+// member returns the member of r called nick.
+func (r *room) member(nick string) (found *client) {
+	r.members.Range(func(_, member any) bool {
+		if strings.EqualFold(member.(*client).Nick, nick) {
+			found = member.(*client)
+			return false
+		}
+		return true
+	})
+	return
+}
+
+// passOwnership hands the room over to another member after the owner left.
+func (r *room) passOwnership() {
+	r.owner = nil
+	r.members.Range(func(_, member any) bool {
+		r.owner = member.(*client)
+		return false
+	})
+	if r.owner != nil {
+		r.broadcast(MSG_MESSAGE, nil, fmt.Sprintf("> %s is now the owner of the room", r.owner.Nick))
+	}
+}
+
+func (r *room) rulesString() string {
+	timer := "off"
+	if r.rules.TurnTimeout > 0 {
+		timer = r.rules.TurnTimeout.String()
+	}
+	bidding := "off, the landlord is picked at random"
+	if r.rules.Bidding {
+		bidding = "on"
+	}
+	return fmt.Sprintf("   seats: %d\n   turn timer: %s\n   bidding: %s", r.rules.NumPlayers, timer, bidding)
+}
+
+func (r *room) settings() string {
+	msg := "> room " + r.name
+	var flags []string
+	if r.code != "" {
+		flags = append(flags, "private, invite code "+r.code)
+	}
+	if r.locked {
+		flags = append(flags, "locked")
+	}
+	if len(flags) > 0 {
+		msg += " (" + strings.Join(flags, ", ") + ")"
+	}
+	if r.owner != nil {
+		msg += "\n   owner: " + r.owner.Nick
+	}
+	return msg + "\n" + r.rulesString()
+}
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// QUEUE_TOLERANCE is the rating difference accepted at a table right
+	// away, it grows by QUEUE_WIDEN for every QUEUE_WIDEN_AFTER waited.
+	QUEUE_TOLERANCE   = 100
+	QUEUE_WIDEN       = 50
+	QUEUE_WIDEN_AFTER = 15 * time.Second
+	QUEUE_INTERVAL    = 2 * time.Second
+	// QUEUE_HISTORY is the number of recent waits the estimate is based on
+	QUEUE_HISTORY = 20
+)
+
+type queued struct {
+	c      *client
+	rating int
+	since  time.Time
+}
+
+func (q *queued) tolerance(now time.Time) int {
+	return QUEUE_TOLERANCE + QUEUE_WIDEN*int(now.Sub(q.since)/QUEUE_WIDEN_AFTER)
+}
+
+// queue is the matchmaking pool.
+type queue struct {
+	mu      sync.Mutex
+	entries []*queued
+	waits   []time.Duration
+	tables  int
+}
+
+func (q *queue) add(c *client, rating int, now time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.entries = append(q.entries, &queued{c, rating, now})
+}
+
+func (q *queue) remove(c *client) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.removeLocked(c)
+}
+
+func (q *queue) removeLocked(c *client) bool {
+	for i, e := range q.entries {
+		if e.c == c {
+			q.entries = append(q.entries[:i], q.entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (q *queue) contains(c *client) bool {
+	return q.get(c) != nil
+}
+
+func (q *queue) get(c *client) *queued {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, e := range q.entries {
+		if e.c == c {
+			return e
+		}
+	}
+	return nil
+}
+
+func (q *queue) list() []queued {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var entries []queued
+	for _, e := range q.entries {
+		entries = append(entries, *e)
+	}
+	return entries
+}
+
+// match takes the next table of n players off the queue. Players are grouped
+// by rating, as long as the spread of a table is within the tolerance of the
+// player who has waited the longest at it. Tables with players who have
+// waited longer are formed first. It returns nil if no table can be formed.
+func (q *queue) match(n int, now time.Time) []*queued {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if n <= 0 || len(q.entries) < n {
+		return nil
+	}
+	sorted := append([]*queued(nil), q.entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].rating < sorted[j].rating
+	})
+
+	var table []*queued
+	var oldest time.Time
+	for i := 0; i+n <= len(sorted); i++ {
+		group := sorted[i : i+n]
+		first := group[0]
+		for _, e := range group[1:] {
+			if e.since.Before(first.since) {
+				first = e
+			}
+		}
+		if group[n-1].rating-group[0].rating > first.tolerance(now) {
+			continue
+		}
+		if table == nil || first.since.Before(oldest) {
+			table = group
+			oldest = first.since
+		}
+	}
+	for _, e := range table {
+		q.removeLocked(e.c)
+		q.waits = append(q.waits, now.Sub(e.since))
+	}
+	if len(q.waits) > QUEUE_HISTORY {
+		q.waits = q.waits[len(q.waits)-QUEUE_HISTORY:]
+	}
+	return table
+}
+
+// estimate is the average wait of the recently matched players, or zero if
+// nobody has been matched yet.
+func (q *queue) estimate() time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waits) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, wait := range q.waits {
+		total += wait
+	}
+	return total / time.Duration(len(q.waits))
+}
+
+// Matchmake periodically forms tables from the matchmaking queue.
+func (s *server) Matchmake() {
+	for range time.Tick(QUEUE_INTERVAL) {
+		s.commands <- command{CMD_MATCHMAKE, nil, nil}
+	}
+}
+
+func (s *server) queueCommand(c *client, args []string) {
+	if len(args) > 1 && args[1] == "leave" {
+		if !s.queue.remove(c) {
+			c.err(errors.New("> you're not in the queue"))
+			return
+		}
+		c.msg(MSG_MESSAGE, "> you left the queue")
+		return
+	}
+	if s.queue.contains(c) {
+		c.msg(MSG_INFO, s.queueStatus(c))
+		return
+	}
+	if c.room.playing(c) {
+		c.err(errors.New("> you're already in a game"))
+		return
+	}
+	if c.room.resume != nil && c.room.resume.HasSeat(c.Nick) {
+		c.err(errors.New("> you have a seat in the interrupted game"))
+		return
+	}
+	if c.room.game.RemovePlayer(c.ID) {
+		c.room.broadcast(MSG_MESSAGE, c, fmt.Sprintf("> %s is no longer ready", c.Nick))
+		c.room.sendInfo()
+	}
+	s.queue.add(c, s.stats.ratingOf(c.Nick), time.Now())
+	c.msg(MSG_MESSAGE, "> you joined the matchmaking queue, type /queue leave to leave it")
+	c.msg(MSG_INFO, s.queueStatus(c))
+}
+
+func (s *server) queueStatus(c *client) string {
+	e := s.queue.get(c)
+	if e == nil {
+		return "> you're not in the queue"
+	}
+	waited := time.Since(e.since).Round(time.Second)
+	msg := fmt.Sprintf("> %d players in the queue, you have waited %v\n  ", len(s.queue.list()), waited)
+	estimate := s.queue.estimate()
+	switch {
+	case estimate == 0:
+		msg += "estimated wait: unknown"
+	case estimate <= waited:
+		msg += "estimated wait: any moment now"
+	default:
+		msg += fmt.Sprintf("estimated wait: about %v", (estimate - waited).Round(time.Second))
+	}
+	return msg
+}
+
+// matchmake seats the tables found in the queue in new rooms.
+func (s *server) matchmake() {
+	for {
+		table := s.queue.match(s.rules.NumPlayers, time.Now())
+		if table == nil {
+			return
+		}
+		r := s.newRoom(s.tableName(), s.rules)
+		r.matched = true
+		var nicks []string
+		for _, e := range table {
+			s.enter(e.c, r)
+			r.seat(e.c)
+			nicks = append(nicks, fmt.Sprintf("%s (%d)", e.c.Nick, e.rating))
+		}
+		log.Printf("matchmaking: %s seated %s", r.name, strings.Join(nicks, ", "))
+		r.broadcast(MSG_MESSAGE, nil, "> a table was found: "+strings.Join(nicks, ", ")+"\n  the game will start soon...")
+		r.sendInfo()
+		go r.loop()
+	}
+}
+
+func (s *server) tableName() string {
+	for {
+		s.queue.tables++
+		name := fmt.Sprintf("table-%d", s.queue.tables)
+		if s.room(name) == nil {
+			return name
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueueMatch(t *testing.T) {
+	now := time.Now()
+	q := &queue{}
+	alice, bob, carol, dave := &client{Nick: "alice"}, &client{Nick: "bob"}, &client{Nick: "carol"}, &client{Nick: "dave"}
+	q.add(alice, 1500, now)
+	q.add(bob, 1550, now)
+	q.add(carol, 1800, now)
+
+	if table := q.match(3, now); table != nil {
+		t.Errorf("ratings too far apart should not be matched yet, got %d players", len(table))
+	}
+	if q.estimate() != 0 {
+		t.Error("expected no estimate without matched players")
+	}
+
+	// waiting widens the tolerance until carol fits in
+	later := now.Add(4 * QUEUE_WIDEN_AFTER)
+	table := q.match(3, later)
+	if len(table) != 3 {
+		t.Fatalf("expected a table after waiting, got %d players", len(table))
+	}
+	if len(q.list()) != 0 {
+		t.Errorf("matched players should leave the queue, %d left", len(q.list()))
+	}
+	if q.estimate() != 4*QUEUE_WIDEN_AFTER {
+		t.Errorf("unexpected estimate %v", q.estimate())
+	}
+
+	// the closest ratings are seated together
+	q.add(alice, 1500, now)
+	q.add(bob, 2000, now)
+	q.add(carol, 1520, now)
+	q.add(dave, 1540, now)
+	table = q.match(3, now)
+	if len(table) != 3 || table[0].c != alice || table[1].c != carol || table[2].c != dave {
+		t.Errorf("unexpected table %v", table)
+	}
+	if !q.contains(bob) || !q.remove(bob) || q.contains(bob) {
+		t.Error("expected bob to stay in the queue until removed")
+	}
+}
@@ -0,0 +1,634 @@
+package server
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"landlord/config"
+	"landlord/server/util"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	MAIN_ROOM = "main"
+	// invite codes of private rooms are made of INVITE_CODE_SIZE characters
+	// of INVITE_ALPHABET, which leaves out the ones that are easily confused
+	INVITE_CODE_SIZE = 6
+	INVITE_ALPHABET  = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+// room is a table with its own game. Every client is a member of exactly one
+// room, which is the main room after logging in.
+type room struct {
+	s    *server
+	name string
+	// members  map[util.PlayerID]*client
+	members sync.Map
+	game    *util.Game
+	rules   config.Rules
+
+	// resume is a game interrupted by a shutdown, waiting for its players
+	resume *util.Snapshot
+	// matched rooms are reserved for the players seated by the matchmaking
+	// queue or a tournament, and closed after their games
+	matched bool
+	// table is the tournament table played in the room
+	table *tournamentTable
+	match *match
+	// code is the invite code of a private room, which is hidden from the
+	// room list and can only be joined with the code
+	code string
+	// created rooms are closed when their last member leaves
+	created bool
+	done    chan struct{}
+	// ended is signalled when the game in progress ends
+	ended chan struct{}
+	// timer passes the turn of the current player, turns counts the turns
+	// so that the timer of a past turn is ignored
+	timer *time.Timer
+	turns int
+	// waiting is when the tournament table started to wait for the players
+	// of the next deal
+	waiting time.Time
+	// owner is the member who controls a created room
+	owner  *client
+	locked bool
+}
+
+func (s *server) newRoom(name string, rules config.Rules) *room {
+	r := &room{
+		s:     s,
+		name:  name,
+		game:  util.NewGame(),
+		rules: rules,
+		done:  make(chan struct{}),
+		ended: make(chan struct{}, 1),
+	}
+	r.game.NumPlayers = rules.NumPlayers
+	s.rooms.Store(name, r)
+	return r
+}
+
+func (s *server) room(name string) *room {
+	if r, ok := s.rooms.Load(name); ok {
+		return r.(*room)
+	}
+	return nil
+}
+
+// enter moves c from its current room into r.
+func (s *server) enter(c *client, r *room) {
+	if c.room != nil {
+		c.room.leave(c)
+	}
+	c.room = r
+	r.members.Store(c.ID, c)
+	if r.created && r.owner == nil {
+		r.owner = c
+	}
+	c.msg(MSG_MESSAGE, "> you are in the room "+r.name)
+	r.broadcast(MSG_MESSAGE, c, fmt.Sprintf("> %s join the room", c.Nick))
+	r.sendInfo()
+}
+
+// findRoom returns the public room called name or the private room with the
+// invite code.
+func (s *server) findRoom(name string) (found *room) {
+	if r := s.room(name); r != nil && r.code == "" {
+		return r
+	}
+	s.rooms.Range(func(_, r any) bool {
+		if code := r.(*room).code; code != "" && strings.EqualFold(code, name) {
+			found = r.(*room)
+			return false
+		}
+		return true
+	})
+	return
+}
+
+func (s *server) joinRoom(c *client, args []string) {
+	if len(args) < 2 {
+		c.err(errors.New("> usage: /join <room or invite code>"))
+		return
+	}
+	r := s.findRoom(args[1])
+	if r == nil {
+		c.err(fmt.Errorf("> no such room: %s", args[1]))
+		return
+	}
+	if r == c.room {
+		c.err(errors.New("> you're already in this room"))
+		return
+	}
+	if r.matched {
+		c.err(errors.New("> the room is reserved for a matched table"))
+		return
+	}
+	if r.locked {
+		c.err(errors.New("> the room is locked"))
+		return
+	}
+	if c.room.playing(c) {
+		c.err(errors.New("> you can't leave a game in progress"))
+		return
+	}
+	s.enter(c, r)
+}
+
+// createRoom handles /create <name> [--private [code]]. Without a code a
+// random invite code is made up for private rooms.
+func (s *server) createRoom(c *client, args []string) {
+	if len(args) < 2 || len(args) > 4 || (len(args) > 2 && args[2] != "--private") {
+		c.err(errors.New("> usage: /create <name> [--private [invite code]]"))
+		return
+	}
+	name := args[1]
+	if err := validRoomName(name); err != nil {
+		c.err(errors.New("> " + err.Error()))
+		return
+	}
+	if s.room(name) != nil || s.findRoom(name) != nil {
+		c.err(fmt.Errorf("> the room %s already exists", name))
+		return
+	}
+	if c.room.playing(c) {
+		c.err(errors.New("> you can't leave a game in progress"))
+		return
+	}
+	code := ""
+	if len(args) == 4 {
+		code = args[3]
+		if err := validRoomName(code); err != nil {
+			c.err(errors.New("> invalid invite code: " + err.Error()))
+			return
+		}
+		if s.room(code) != nil || s.findRoom(code) != nil {
+			c.err(errors.New("> the invite code is already in use, pick another one"))
+			return
+		}
+	} else if len(args) == 3 {
+		var err error
+		if code, err = s.inviteCode(); err != nil {
+			log.Printf("unable to make up an invite code: %s", err.Error())
+			c.err(errors.New("> unable to create the room"))
+			return
+		}
+	}
+
+	r := s.newRoom(name, s.rules)
+	r.created = true
+	r.code = code
+	r.owner = c
+	go r.loop()
+	log.Printf("%s created the room %s (private: %v)", c.Nick, name, code != "")
+	s.enter(c, r)
+	if code != "" {
+		c.msg(MSG_MESSAGE, fmt.Sprintf("> the room is private, invite others with: /join %s", code))
+	}
+}
+
+func validRoomName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("name must not be empty")
+	case len(name) > MAX_NICK_SIZE:
+		return fmt.Errorf("name must have at most %d characters", MAX_NICK_SIZE)
+	case strings.ContainsAny(name, " \t_"):
+		return errors.New("name must not contain spaces or underscores")
+	case name[0] == '#' || name[0] == '/' || name[0] == '-':
+		return errors.New("name must not start with #, / or -")
+	}
+	return nil
+}
+
+func (s *server) inviteCode() (string, error) {
+	for {
+		byts := make([]byte, INVITE_CODE_SIZE)
+		if _, err := rand.Read(byts); err != nil {
+			return "", err
+		}
+		for i, b := range byts {
+			byts[i] = INVITE_ALPHABET[int(b)%len(INVITE_ALPHABET)]
+		}
+		if code := string(byts); s.findRoom(code) == nil && s.room(code) == nil {
+			return code, nil
+		}
+	}
+}
+
+// closeRoom sends the members of r back to the main room.
+func (s *server) closeRoom(name string) {
+	r := s.room(name)
+	if r == nil || r == s.main {
+		return
+	}
+	s.rooms.Delete(name)
+	close(r.done)
+	r.members.Range(func(_, member any) bool {
+		member.(*client).msg(MSG_MESSAGE, "> the table is closed, back to the main room")
+		s.enter(member.(*client), s.main)
+		return true
+	})
+	log.Printf("room %s closed", name)
+	if r.table != nil && s.tournaments.roundDone() {
+		s.nextRound()
+	}
+}
+
+func (s *server) listRooms(c *client) {
+	var rooms []string
+	s.rooms.Range(func(_, r any) bool {
+		if r.(*room).code == "" || r == c.room {
+			rooms = append(rooms, r.(*room).summary())
+		}
+		return true
+	})
+	sort.Strings(rooms)
+	c.msg(MSG_MESSAGE, "> rooms:\n"+strings.Join(rooms, "\n"))
+}
+
+// resumeRoom returns the room whose interrupted game has a seat for nick.
+func (s *server) resumeRoom(nick string) (found *room) {
+	s.rooms.Range(func(_, r any) bool {
+		if r.(*room).resume != nil && r.(*room).resume.HasSeat(nick) {
+			found = r.(*room)
+			return false
+		}
+		return true
+	})
+	return
+}
+
+func (r *room) summary() string {
+	summary := fmt.Sprintf(" - %s: %s, %d members, %d/%d ready",
+		r.name, util.State(r.game.State), lenSyncMap(&r.members), r.game.NumReady(), r.game.NumPlayers)
+	if r.match != nil {
+		summary += ", match " + r.match.progress()
+	}
+	if r.code != "" {
+		summary += ", private"
+	}
+	return summary
+}
+
+// seat makes c a ready player of the next game.
+func (r *room) seat(c *client) {
+	player, err := r.game.AddPlayer(c.ID, c.Nick)
+	if err != nil {
+		log.Printf("unable to seat %s in %s: %s", c.Nick, r.name, err.Error())
+		return
+	}
+	player.IsReady = true
+}
+
+// playing reports whether c takes part in the game in progress.
+func (r *room) playing(c *client) bool {
+	return r.game.State == util.STATE_PLAYING && r.game.ContainsPlayer(c.ID)
+}
+
+// leave removes c from the room, which ends the game c is playing in.
+func (r *room) leave(c *client) {
+	r.members.Delete(c.ID)
+	if r.match != nil && r.match.Seats != nil && r.match.hasSeat(c.Nick) {
+		r.abandonMatch(c.Nick + " left")
+	}
+	player, seated := r.game.Player(c.ID)
+	r.game.RemovePlayer(c.ID)
+	if seated && r.game.State == util.STATE_PLAYING {
+		r.s.metrics.disconnectedMidGame()
+		r.broadcast(MSG_MESSAGE, c, fmt.Sprintf("> %s left the room, game ends", c.Nick))
+		r.handle(r.game.Abort(player.Seat))
+	} else {
+		r.broadcast(MSG_MESSAGE, c, fmt.Sprintf("> %s left the room", c.Nick))
+	}
+	r.sendInfo()
+	if r.created && lenSyncMap(&r.members) == 0 {
+		r.s.closeRoom(r.name)
+	} else if r.owner == c {
+		r.passOwnership()
+	}
+}
+
+// loop moves the game of the room from one state to the next. Every step
+// holds the lock of the server state, but not while waiting.
+func (r *room) loop() (err error) {
+	mu := &r.s.mu
+	for {
+		mu.Lock()
+		state := r.game.State
+		mu.Unlock()
+		switch state {
+		case util.STATE_WAITING:
+			mu.Lock()
+			ready := r.game.NumReady() == r.game.NumPlayers
+			if ready {
+				r.game.NextState()
+			} else if r.table != nil {
+				r.forfeitAbsent()
+			}
+			mu.Unlock()
+			if ready {
+				continue
+			}
+			select {
+			case <-r.done:
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+		case util.STATE_PLAYING:
+			time.Sleep(1 * time.Second)
+			mu.Lock()
+			r.start()
+			mu.Unlock()
+			select {
+			case <-r.ended:
+				log.Printf("game ends in %s", r.name)
+			case <-r.done:
+				return
+			}
+		case util.STATE_OVER:
+			time.Sleep(500 * time.Millisecond)
+			mu.Lock()
+			closed := r.over()
+			mu.Unlock()
+			if closed {
+				r.s.commands <- command{CMD_CLOSE_ROOM, nil, []string{r.name}}
+				return
+			}
+		}
+	}
+}
+
+// over sets up the next game after a game is over. It reports whether the
+// room is to be closed instead.
+func (r *room) over() bool {
+	// the tables that opened during the game were waiting for its players
+	r.members.Range(func(_, member any) bool {
+		c := member.(*client)
+		if table := r.s.tableRoom(c); table != nil && table != r {
+			r.s.takeTableSeat(c, table)
+		}
+		return true
+	})
+	if r.table != nil && !r.s.tournaments.tableDone(r.table) {
+		r.game = util.NewGame()
+		r.game.NumPlayers = r.rules.NumPlayers
+		r.waiting = time.Now()
+		r.broadcast(MSG_MESSAGE, nil, fmt.Sprintf("> next deal: %d/%d", r.table.Played+1, r.s.tournaments.deals()))
+		r.members.Range(func(_, c any) bool {
+			r.seat(c.(*client))
+			return true
+		})
+		r.sendInfo()
+		return false
+	}
+	if r.match != nil && r.match.Seats != nil && r.nextDeal() {
+		return false
+	}
+	if r.matched {
+		return true
+	}
+	r.broadcast(MSG_MESSAGE, nil, "> type /ready to start a new game or /quit to quit")
+	r.game = util.NewGame()
+	r.game.NumPlayers = r.rules.NumPlayers
+	r.sendInfo()
+	return false
+}
+
+// start deals the cards of a new game, or continues the interrupted game
+// once all of its players are back.
+func (r *room) start() {
+	g := r.game
+	if g.State != util.STATE_PLAYING {
+		return
+	}
+	var events []util.Event
+	var err error
+	if r.resume != nil {
+		events, err = r.restore()
+	} else {
+		players := g.Seated()
+		first := util.R.Intn(len(players))
+		if r.s.deck != nil {
+			g.Deck, first = *r.s.deck, 0
+		}
+		if r.match != nil {
+			players, first = r.match.seat(players)
+		}
+		events, err = g.Deal(players, first, r.rules.Bidding)
+	}
+	if err != nil {
+		log.Printf("unable to start the game in %s: %s", r.name, err.Error())
+		r.broadcast(MSG_MESSAGE, nil, "> unable to start the game")
+		g.State = util.STATE_OVER
+		r.signalEnded()
+		return
+	}
+	r.handle(events)
+}
+
+// finishGame announces the scores of a game played to the end and records
+// the result for the registered players.
+func (r *room) finishGame(result util.Result) {
+	g := r.game
+	msg := fmt.Sprintf("> scores (x%d", result.Multiplier)
+	if result.Spring {
+		msg += ", spring"
+	}
+	msg += "):"
+	var accounts []string
+	for _, player := range g.Order {
+		msg += fmt.Sprintf("\n   %s: %+d", player.Nick, result.Scores[player.Nick])
+		if c := r.client(player); c != nil && c.Account {
+			accounts = append(accounts, player.Nick)
+		}
+	}
+	r.broadcast(MSG_MESSAGE, nil, msg)
+	if r.match != nil {
+		r.match.record(result)
+		r.broadcast(MSG_MESSAGE, nil, r.match.String())
+	}
+
+	st := r.s.stats
+	if err := st.record(result, accounts); err != nil {
+		log.Printf("unable to record the game result: %s", err.Error())
+	}
+	if r.table != nil {
+		if err := r.s.tournaments.record(r.table, result); err != nil {
+			log.Printf("unable to save the tournament: %s", err.Error())
+		}
+	}
+	if len(accounts) > 0 {
+		msg = "> ratings:"
+		for _, nick := range accounts {
+			ps, _ := st.get(nick)
+			msg += fmt.Sprintf("\n   %s: %s", nick, ps.ratingString())
+		}
+		r.broadcast(MSG_MESSAGE, nil, msg)
+		r.s.broadcast(MSG_STATS, nil, st.leaderboardString())
+	}
+}
+
+func (r *room) broadcast(msgType messageType, sender *client, msg string) {
+	r.members.Range(func(id, member any) bool {
+		if sender != nil && id == sender.ID {
+			return true
+		}
+		member.(*client).msg(msgType, msg)
+		return true
+	})
+}
+
+// sendInfo sends the state of the room and its players to every member.
+func (r *room) sendInfo() {
+	r.broadcast(MSG_ROOM_INFO, nil, util.State(r.game.State)+"_"+strings.Join(r.listPlayers(), "\n"))
+}
+
+// listPlayers lists the seats in play order, followed by the members who
+// are only watching. The current player is marked with an arrow.
+func (r *room) listPlayers() []string {
+	var players []string
+	g := r.game
+	seated := g.Order
+	if g.State == util.STATE_WAITING || seated == nil {
+		seated = g.Seated()
+	}
+	for _, player := range seated {
+		name := player.Nick
+		if c := r.client(player); c != nil {
+			name = r.s.displayName(c)
+		}
+		line := " -"
+		if g.State == util.STATE_PLAYING && player == g.CurrentPlayer {
+			line += ">"
+		}
+		line += fmt.Sprintf(" seat %d: %s", player.Seat+1, name)
+		switch {
+		case g.State == util.STATE_WAITING && player.IsReady:
+			line += " (ready)"
+		case g.State != util.STATE_WAITING && player.Position == util.LANDLORD:
+			line += " (landlord)"
+		}
+		players = append(players, line)
+	}
+	var watching []string
+	r.members.Range(func(_, c any) bool {
+		if !g.ContainsPlayer(c.(*client).ID) {
+			watching = append(watching, " - "+r.s.displayName(c.(*client))+" (watching)")
+		}
+		return true
+	})
+	sort.Strings(watching)
+	return append(players, watching...)
+}
+
+func (r *room) endGame() {
+	if r.resume != nil && r.game.State == util.STATE_WAITING {
+		r.resume = nil
+		r.broadcast(MSG_MESSAGE, nil, "> the interrupted game was discarded by an admin")
+		if r.matched {
+			r.game.State = util.STATE_OVER
+		}
+		return
+	}
+	if r.game.State != util.STATE_PLAYING {
+		return
+	}
+	r.broadcast(MSG_MESSAGE, nil, "> the game was ended by an admin")
+	r.abandonMatch("the game was ended by an admin")
+	r.handle(r.game.Abort(-1))
+}
+
+func (r *room) ready(c *client) {
+	if r.game.State == util.STATE_OVER {
+		// the game is about to be replaced by the next one
+		c.err(errors.New("> the game just ended, type /ready again once the next game is set up"))
+		return
+	}
+	if r.resume != nil && !r.resume.HasSeat(c.Nick) {
+		c.err(errors.New("> waiting for the players of the interrupted game to come back"))
+		return
+	}
+	if r.match != nil && !r.match.hasSeat(c.Nick) {
+		c.err(errors.New("> the seats are taken by the players of the match"))
+		return
+	}
+	if r.s.queue.contains(c) {
+		c.err(errors.New("> you're in the matchmaking queue, type /queue leave first"))
+		return
+	}
+	player, err := r.game.AddPlayer(c.ID, c.Nick)
+	if err != nil {
+		c.err(err)
+		return
+	}
+	if player.IsReady {
+		c.err(errors.New("> you're already ready"))
+		return
+	}
+	player.IsReady = true
+
+	c.msg(MSG_MESSAGE, fmt.Sprintf("> you are ready for the game at seat %d. %v/%v", player.Seat+1, r.game.NumReady(), r.game.NumPlayers))
+
+	// c.prompt()
+	r.broadcast(MSG_MESSAGE, c, fmt.Sprintf("> %s is ready. %v/%v", c.Nick, r.game.NumReady(), r.game.NumPlayers))
+	r.sendInfo()
+	if r.game.NumReady() == r.game.NumPlayers {
+		r.broadcast(MSG_MESSAGE, nil, "> all players are ready. game will start soon...")
+	}
+}
+
+func (r *room) viewCards(c *client, args []string) {
+	player, ok := r.game.Player(c.ID)
+	if !ok {
+		return
+	}
+	msg := player.Highlight(r.game.LastUsedCards)
+	if player.Position == util.LANDLORD {
+		msg = "landlord_" + msg
+	} else {
+		msg = "farmer_" + msg
+	}
+
+	c.msg(MSG_PLAYER_STATUS, msg)
+}
+
+func (r *room) useCards(c *client, args []string) {
+	s := r.s
+	parsed, err := util.ParseCards(strings.Join(args[1:], " "))
+	if err != nil {
+		s.metrics.invalidPlay(REASON_UNKNOWN_CARD)
+		c.err(fmt.Errorf("> %w", err))
+		return
+	}
+	var cards []*util.Card
+	for i := range parsed {
+		cards = append(cards, &parsed[i])
+	}
+	if len(cards) == 0 {
+		s.metrics.invalidPlay(REASON_EMPTY)
+		c.err(errors.New("> please select at least one card"))
+		return
+	}
+	r.apply(c, util.Play{Seat: r.seatOf(c), Cards: cards})
+}
+
+func (r *room) pass(c *client) {
+	r.apply(c, util.Pass{Seat: r.seatOf(c)})
+}
+
+func (r *room) bid(c *client, args []string) {
+	points, err := strconv.Atoi(strings.Join(args[1:], ""))
+	if err != nil {
+		r.s.metrics.invalidPlay(REASON_BID)
+		c.err(errors.New("> usage: /bid <points>, from 1 to 3, or /bid 0 to pass"))
+		return
+	}
+	r.apply(c, util.Bid{Seat: r.seatOf(c), Points: points})
+}
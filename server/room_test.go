@@ -0,0 +1,36 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindRoom(t *testing.T) {
+	s := NewServer()
+	team := s.newRoom("team", s.rules)
+	team.code = "ABC123"
+	open := s.newRoom("open", s.rules)
+
+	if s.findRoom("open") != open || s.findRoom(MAIN_ROOM) != s.main {
+		t.Error("expected public rooms to be found by name")
+	}
+	if s.findRoom("team") != nil {
+		t.Error("expected private rooms to be hidden")
+	}
+	if s.findRoom("abc123") != team {
+		t.Error("expected private rooms to be found by invite code")
+	}
+
+	code, err := s.inviteCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != INVITE_CODE_SIZE || strings.Trim(code, INVITE_ALPHABET) != "" {
+		t.Errorf("invalid invite code %q", code)
+	}
+	for _, name := range []string{"", "a b", "#x", "--private", "a_b", "waytoolongforaroomname"} {
+		if validRoomName(name) == nil {
+			t.Errorf("expected %q to be rejected", name)
+		}
+	}
+}
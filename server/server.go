@@ -2,268 +2,426 @@ package server
 
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
-	"io"
+	"landlord/config"
 	"landlord/server/util"
 	"log"
 	"net"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
 )
 
 type server struct {
 	commands chan command
-	// members  map[net.Addr]*client
+	// mu guards the state of the server, its rooms and their games.
+	// RunCommands holds it while running a command, the other goroutines
+	// lock it to read or change the state, but never while sending a
+	// command, which would block RunCommands
+	mu sync.Mutex
+	// members  map[util.PlayerID]*client
 	members sync.Map
-	game    *util.Game
+	// rooms map[string]*room
+	rooms   sync.Map
+	main    *room
+	queue   *queue
+	started time.Time
+	metrics *metrics
+
+	idleTimeout time.Duration
+	dataDir     string
+	// rules are the default rules of new rooms
+	rules config.Rules
+
+	accounts    *accounts
+	stats       *stats
+	tournaments *tournaments
+	// nicks guards nickname checks during the handshake
+	nicks       sync.Mutex
+	bans        *bans
+	connections *connections
+	violations  *violations
+
+	// config is the config the server was started or reloaded with
+	config config.Config
+	// reload reads the config again for the console
+	reload func() (config.Config, error)
+	// deck replaces the shuffled deck of every game if set, and the first
+	// seat starts, which makes the games of the tests repeatable
+	deck *util.Deck
 }
 
 func NewServer() *server {
-	return &server{
+	s := &server{
 		commands: make(chan command, 1),
-		// members:  make(map[net.Addr]*client),
-		members: sync.Map{},
-		game:    util.NewGame(),
+		members:  sync.Map{},
+		queue:    &queue{},
+		started:  time.Now(),
+		metrics:  newMetrics(),
+
+		idleTimeout: config.Default().IdleTimeout.Duration(),
+		rules:       config.Default().Rules,
+		dataDir:     config.Default().DataDir,
+		accounts: &accounts{
+			path:  filepath.Join(config.Default().DataDir, ACCOUNTS_FILE),
+			users: make(map[string]*account),
+		},
+		stats: &stats{
+			dir:     config.Default().DataDir,
+			players: make(map[string]*playerStats),
+		},
+		tournaments: &tournaments{dir: config.Default().DataDir},
+		bans:        newBans(config.Default().DataDir),
+		connections: &connections{count: make(map[string]int)},
+		violations:  &violations{counts: make(map[string]int)},
+		config:      config.Default(),
 	}
+	s.main = s.newRoom(MAIN_ROOM, s.rules)
+	return s
 }
 
-func (s *server) NewClient(conn net.Conn) {
-	c := &client{
-		Nick:     "#anonymous",
-		commands: s.commands,
-		Conn:     conn,
+// Load reads the persistent data from the data directory.
+func (s *server) Load() (err error) {
+	if s.accounts, err = loadAccounts(s.dataDir); err != nil {
+		return
+	}
+	if s.stats, err = loadStats(s.dataDir); err != nil {
+		return
 	}
-	// s.members[conn.RemoteAddr()] = c
-	s.members.Store(conn.RemoteAddr(), c)
-	nickname, err := bufio.NewReader(conn).ReadString('\n')
-	nickname = strings.Trim(nickname, "\r\n")
-	for nickname == "" || err != nil {
-		c.Conn.Write([]byte(""))
-		nickname, err = bufio.NewReader(conn).ReadString('\n')
-		nickname = strings.Trim(nickname, "\r\n")
+	if s.tournaments, err = loadTournaments(s.dataDir); err != nil {
+		return
 	}
-	c.Conn.Write([]byte("ok\n"))
-	nickname = strings.Trim(nickname, "\n")
-	c.Nick = nickname
-	time.Sleep(500 * time.Millisecond)
-	c.msg(MSG_MESSAGE, "> welcome to the server, "+c.Nick+"\n  type /ready to join the games")
-	s.broadcast(MSG_MESSAGE, c, fmt.Sprintf("> %s join the room", c.Nick))
-	s.broadcast(MSG_ROOM_INFO, nil, util.State(s.game.State)+"_"+strings.Join(s.listPlayers(), "\n"))
-	c.readInput()
+	s.bans, err = loadBans(s.dataDir)
+	return
 }
 
-func (s *server) RemoveClosedClient() {
-	for {
-		s.members.Range(func(addr, c any) bool {
-			addr, ok1 := addr.(net.Addr)
-			client, ok2 := c.(*client)
-			if ok1 && ok2 {
-				_, err := client.Conn.Write([]byte{})
-				if err != nil && !(errors.Is(err, net.ErrClosed) &&
-					errors.Is(err, io.EOF) &&
-					errors.Is(err, syscall.EPIPE)) {
-					log.Println("client has disconnected:", addr)
-					client.Conn.Close()
-					s.members.Delete(addr)
-				}
-			}
-			return true
-		})
+// Configure applies the settings of cfg. The number of players of the main
+// room only changes for the next game if a game is in progress.
+func (s *server) Configure(cfg config.Config) {
+	s.config = cfg
+	s.idleTimeout = cfg.IdleTimeout.Duration()
+	s.dataDir = cfg.DataDir
+	s.rules = cfg.Rules
+	s.main.rules = cfg.Rules
+	if s.main.game.State == util.STATE_WAITING {
+		s.main.game.NumPlayers = cfg.Rules.NumPlayers
 	}
-
+	debugLogging.Store(cfg.LogLevel == config.LEVEL_DEBUG)
 }
 
-func (s *server) RunCommands() (err error) {
-	for command := range s.commands {
-		sender := command.sender
-		sender.Conn.SetDeadline(time.Now().Add(300 * time.Second))
-		switch command.id {
-		case CMD_MESSAGE:
-			err = sender.msg(MSG_CHAT, sender.Nick+": "+command.args[0])
-			if err != nil {
-				return err
-			}
-			s.broadcast(MSG_CHAT, sender, sender.Nick+": "+command.args[0])
-		case CMD_LIST_COMMANDS:
-			s.listCommands(sender)
-		case CMD_LIST_PLAYERS:
-			s.listPlayers()
-		case CMD_QUIT:
-			s.quit(sender)
-		case CMD_READY:
-			if s.game.State != util.STATE_PLAYING {
-				s.ready(sender)
-			} else {
-				sender.err(errors.New("> you're already in a game"))
-			}
-		case CMD_VIEW_CARDS:
-			if s.game.State == util.STATE_PLAYING && s.game.ContainsPlayer(sender.Conn.RemoteAddr()) {
-				s.viewCards(sender, command.args)
-			} else {
-				sender.err(errors.New("> you must first join a game"))
-			}
-		case CMD_USE_CARDS:
-			if s.game.State == util.STATE_PLAYING && s.game.ContainsPlayer(sender.Conn.RemoteAddr()) {
-				s.useCards(sender, command.args)
-			} else {
-				sender.err(errors.New("> you must first join a game"))
-			}
-		case CMD_PASS:
-			if s.game.State == util.STATE_PLAYING && s.game.ContainsPlayer(sender.Conn.RemoteAddr()) {
-				s.pass(sender)
-			} else {
-				sender.err(errors.New("> you must first join a game"))
-			}
-		case CMD_UNKNOWN:
-			sender.err(errors.New("> unknown command: " + command.args[0]))
-
+func (s *server) NewClient(conn net.Conn) {
+	s.mu.Lock()
+	limits := s.config.Limits
+	s.mu.Unlock()
+	c := &client{
+		ID:           newPlayerID(),
+		Nick:         "#anonymous",
+		commands:     s.commands,
+		Conn:         conn,
+		out:          make(chan []byte, OUTBOUND_QUEUE_SIZE),
+		written:      make(chan struct{}),
+		metrics:      s.metrics,
+		commandLimit: newLimiter(limits.CommandRate, limits.CommandBurst),
+		chatLimit:    newLimiter(limits.ChatRate, limits.ChatBurst),
+		violations:   s.violations,
+	}
+	if tc, ok := conn.(*tls.Conn); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), TLS_HANDSHAKE_TIMEOUT)
+		err := tc.HandshakeContext(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("tls handshake with %s failed: %s", conn.RemoteAddr(), err.Error())
+			conn.Close()
+			return
 		}
 	}
-	return
-}
-
-func (s *server) GameLoop() (err error) {
+	if s.bans.ip(conn.RemoteAddr()) {
+		s.violations.add(VIOLATION_BANNED, "", conn.RemoteAddr(), "connection from a banned address")
+		conn.Write([]byte("you are banned from this server\n"))
+		conn.Close()
+		return
+	}
+	host := hostOf(conn.RemoteAddr())
+	if !s.connections.acquire(host, limits.ConnectionsPerIP) {
+		s.violations.add(VIOLATION_CONNECTIONS, "", conn.RemoteAddr(), "too many connections from the address")
+		conn.Write([]byte("too many connections from your address\n"))
+		conn.Close()
+		return
+	}
+	defer s.connections.release(host)
+	reader := bufio.NewReader(conn)
 	for {
-		switch s.game.State {
-		case util.STATE_WAITING:
-			if s.game.NumReady() == s.game.NumPlayers {
-				s.game.NextState()
-			}
-		case util.STATE_PLAYING:
-			time.Sleep(1 * time.Second)
-			err = s.play()
-			if err != nil {
-				log.Println(err)
-				return
-			}
-		case util.STATE_OVER:
-			time.Sleep(500 * time.Millisecond)
-			s.broadcast(MSG_MESSAGE, nil, "> type /ready to start a new game or /quit to quit")
-			numPlayers := s.game.NumPlayers
-			s.game = util.NewGame()
-			s.game.NumPlayers = numPlayers
-			s.broadcast(MSG_ROOM_INFO, nil, util.State(s.game.State)+"_"+strings.Join(s.listPlayers(), "\n"))
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			conn.Close()
+			return
 		}
+		if c.throttle(c.commandLimit, VIOLATION_COMMANDS) {
+			c.Conn.Write([]byte("too many attempts, slow down\n"))
+			continue
+		}
+		if err = s.authenticate(c, strings.Trim(line, "\r\n ")); err == nil {
+			break
+		}
+		c.Conn.Write([]byte(err.Error() + "\n"))
 	}
+	c.Conn.Write([]byte("ok\n"))
+	log.Printf("%s logged in as %s (registered: %v)", conn.RemoteAddr(), c.Nick, c.Account)
+	go c.writeOutput()
+	c.commands <- command{CMD_LOGIN, c, nil}
+	c.readInput(reader)
+	c.commands <- command{CMD_DISCONNECTED, c, nil}
 }
 
-func (s *server) play() (err error) {
-	var players []*util.Player
-	g := s.game
-	g.Players.Range(func(_, player any) bool {
-		if player, ok := player.(*util.Player); ok {
-			err = player.Deal(&g.Deck, 17)
-			if err != nil {
-				return false
-			}
-			player.Sort()
-			players = append(players, player)
-		}
-		return true
-	})
-	if err != nil {
-		return err
+// login welcomes c and takes it to its room, which is the room of its
+// interrupted game or its tournament table if it has one.
+func (s *server) login(c *client) {
+	if c.Account {
+		c.msg(MSG_MESSAGE, "> welcome back, "+c.Nick+"\n  type /ready to join the games or /queue to find a table")
+	} else {
+		c.msg(MSG_MESSAGE, "> welcome to the server, "+c.Nick+"\n  type /ready to join the games or /queue to find a table")
 	}
+	c.msg(MSG_STATS, s.stats.leaderboardString())
+	if r := s.resumeRoom(c.Nick); r != nil {
+		s.enter(c, r)
+		c.msg(MSG_MESSAGE, "> taking back your seat in the interrupted game")
+		r.ready(c)
+	} else if r := s.tableRoom(c); r != nil {
+		s.takeTableSeat(c, r)
+	} else {
+		s.enter(c, s.main)
+	}
+}
 
-	time.Sleep(500 * time.Millisecond)
-
-	landlordIdx := util.R.Intn(g.NumPlayers)
-	g.Landlord = players[landlordIdx]
-	g.Landlord.Deal(&g.Deck, 3)
-	g.Landlord.Position = util.LANDLORD
+// authenticate handles the first line sent by a client, which is either a
+// guest nickname or one of
+//
+//	/register <nickname> <password>
+//	/login <nickname> <password>
+//
+// Nicknames are unique among connected clients and registered nicknames
+// can only be used by logging in. Once authenticated, c is a member of the
+// server.
+func (s *server) authenticate(c *client, line string) error {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return errors.New("nickname must not be empty")
+	}
+	if args[0] != "/register" && args[0] != "/login" {
+		if err := validNick(line); err != nil {
+			return err
+		}
+		if s.bans.nick(line) {
+			s.violations.add(VIOLATION_BANNED, line, c.Conn.RemoteAddr(), "login with a banned nickname")
+			return errors.New("nickname is banned")
+		}
+		s.nicks.Lock()
+		defer s.nicks.Unlock()
+		if s.accounts.registered(line) {
+			return errors.New("nickname is registered, use /login <nickname> <password>")
+		}
+		if s.findClient(line) != nil {
+			return errors.New("nickname is already in use")
+		}
+		c.Nick = line
+		s.members.Store(c.ID, c)
+		return nil
+	}
 
-	c, ok := s.members.Load(g.Landlord.Conn.RemoteAddr())
-	if !ok {
-		log.Println("unable to load client")
-		g.NextState()
-		return
+	if len(args) != 3 {
+		return fmt.Errorf("usage: %s <nickname> <password>", args[0])
 	}
-	c.(*client).msg(MSG_MESSAGE, "> you are the landlord")
-	s.broadcast(MSG_MESSAGE, c.(*client), fmt.Sprintf("> %s is the landlord", c.(*client).Nick))
-	s.broadcast(MSG_ROOM_INFO, nil, util.State(s.game.State)+"_"+strings.Join(s.listPlayers(), "\n"))
-	currentPlayerIdx := landlordIdx
-	time.Sleep(500 * time.Millisecond)
-	s.members.Range(func(_, c any) bool {
-		if player, ok := g.Players.Load(c.(*client).Conn.RemoteAddr()); ok {
-			if player.(*util.Player).Position != util.LANDLORD {
-				err = c.(*client).msg(MSG_PLAYER_STATUS, "farmer_"+player.(*util.Player).String())
-				if err != nil {
-					return false
-				}
-			}
+	nick, password := args[1], args[2]
+	if err := validNick(nick); err != nil {
+		return err
+	}
+	if s.bans.nick(nick) {
+		s.violations.add(VIOLATION_BANNED, nick, c.Conn.RemoteAddr(), "login with a banned nickname")
+		return errors.New("nickname is banned")
+	}
+	s.nicks.Lock()
+	defer s.nicks.Unlock()
+	if s.findClient(nick) != nil {
+		return errors.New("nickname is already in use")
+	}
+	var err error
+	if args[0] == "/register" {
+		err = s.accounts.register(nick, password)
+		if err == nil {
+			log.Printf("account registered: %s", nick)
 		}
-		return true
-	})
+	} else {
+		nick, err = s.accounts.login(nick, password)
+	}
 	if err != nil {
 		return err
 	}
+	c.Nick = nick
+	c.Account = true
+	s.members.Store(c.ID, c)
+	return nil
+}
 
-	for {
-		g.CurrentPlayer = players[currentPlayerIdx]
-		if g.CurrentPlayer == g.LastPlayer {
-			g.LastUsedCards = []*util.Card{}
+// RunCommands runs the commands of the clients, the admins and the server
+// one after another, which makes it the owner of the state of the server.
+func (s *server) RunCommands() (err error) {
+	for command := range s.commands {
+		s.mu.Lock()
+		s.run(command)
+		s.mu.Unlock()
+	}
+	return
+}
+
+func (s *server) run(command command) {
+	sender := command.sender
+	if sender != nil {
+		// the client disconnected after sending the command
+		if _, ok := s.members.Load(sender.ID); !ok {
+			return
+		}
+		if command.id < CMD_KICK {
+			sender.Conn.SetReadDeadline(time.Now().Add(s.idleTimeout))
 		}
-		c, _ := s.members.Load(g.CurrentPlayer.Conn.RemoteAddr())
-		if _, ok := c.(*client); !ok {
-			log.Println("unable to load client")
-			g.NextState()
+	}
+	switch command.id {
+	case CMD_MESSAGE:
+		if err := sender.msg(MSG_CHAT, sender.Nick+": "+command.args[0]); err != nil {
 			break
 		}
-		s.broadcast(MSG_ROOM_INFO, nil, util.State(s.game.State)+"_"+strings.Join(s.listPlayers(), "\n"))
-		c.(*client).msg(MSG_INFO, "> it's your turn")
-		time.Sleep(300 * time.Millisecond)
-		if len(g.LastUsedCards) > 0 {
-			c.(*client).msg(MSG_INFO, fmt.Sprintf("  you have to beat %v from %v", util.CardsToString(g.LastUsedCards), g.LastPlayer.Nick))
+		sender.room.broadcast(MSG_CHAT, sender, sender.Nick+": "+command.args[0])
+	case CMD_LIST_COMMANDS:
+		s.listCommands(sender)
+	case CMD_LIST_PLAYERS:
+		sender.msg(MSG_MESSAGE, "> players:\n"+strings.Join(sender.room.listPlayers(), "\n"))
+	case CMD_QUIT:
+		s.quit(sender)
+	case CMD_READY:
+		if sender.room.game.State != util.STATE_PLAYING {
+			sender.room.ready(sender)
 		} else {
-			c.(*client).msg(MSG_INFO, "  you can play any cards")
+			sender.err(errors.New("> you're already in a game"))
 		}
-		s.viewCards(c.(*client), []string{})
-		if len(g.CurrentPlayer.Recommend(s.game.LastUsedCards)) == 0 {
-			log.Println(s.game.LastUsedCards)
-			c.(*client).msg(MSG_INFO, "> you can't beat the last player")
+	case CMD_VIEW_CARDS:
+		if sender.room.playing(sender) {
+			sender.room.viewCards(sender, command.args)
 		} else {
-			c.(*client).msg(MSG_INFO, fmt.Sprintf("> recommend: %v", g.CurrentPlayer.Recommend(g.LastUsedCards)))
+			sender.err(errors.New("> you must first join a game"))
 		}
-		s.broadcast(MSG_INFO, c.(*client), fmt.Sprintf("> waiting for %s's action...", c.(*client).Nick))
-
-		cards := <-g.CurrentUsedCards
-		if g.PlayerNum != g.NumPlayers {
-			log.Println("ln 237")
-			log.Println(g.PlayerNum, g.NumPlayers)
-			break
+	case CMD_USE_CARDS:
+		if sender.room.playing(sender) {
+			sender.room.useCards(sender, command.args)
+		} else {
+			s.metrics.invalidPlay(REASON_NOT_IN_GAME)
+			sender.err(errors.New("> you must first join a game"))
+		}
+	case CMD_PASS:
+		if sender.room.playing(sender) {
+			sender.room.pass(sender)
+		} else {
+			s.metrics.invalidPlay(REASON_NOT_IN_GAME)
+			sender.err(errors.New("> you must first join a game"))
 		}
-		if len(g.CurrentPlayer.Cards) == 0 {
-			s.broadcast(MSG_MESSAGE, nil, fmt.Sprintf("> %s won the game", c.(*client).Nick))
-			g.NextState()
+	case CMD_BID:
+		if sender.room.playing(sender) {
+			sender.room.bid(sender, command.args)
+		} else {
+			s.metrics.invalidPlay(REASON_NOT_IN_GAME)
+			sender.err(errors.New("> you must first join a game"))
+		}
+	case CMD_UNKNOWN:
+		sender.err(errors.New("> unknown command: " + command.args[0]))
+	case CMD_STATS:
+		s.showStats(sender, command.args)
+	case CMD_LEADERBOARD:
+		sender.msg(MSG_MESSAGE, "> leaderboard:\n"+s.stats.leaderboardString())
+	case CMD_ROOMS:
+		s.listRooms(sender)
+	case CMD_JOIN:
+		s.joinRoom(sender, command.args)
+	case CMD_CREATE:
+		s.createRoom(sender, command.args)
+	case CMD_ROOM:
+		s.roomCommand(sender, command.args)
+	case CMD_QUEUE:
+		s.queueCommand(sender, command.args)
+	case CMD_TOURNAMENT:
+		s.tournamentCommand(sender, command.args)
+	case CMD_MATCH:
+		s.matchCommand(sender, command.args)
+	case CMD_KICK:
+		s.kick(sender)
+	case CMD_END_GAME:
+		if r := s.room(command.args[0]); r != nil {
+			r.endGame()
+		}
+	case CMD_NOTICE:
+		s.broadcast(MSG_MESSAGE, nil, "> [notice] "+command.args[0])
+	case CMD_MATCHMAKE:
+		s.matchmake()
+	case CMD_CLOSE_ROOM:
+		s.closeRoom(command.args[0])
+	case CMD_START_TOURNAMENT:
+		if err := s.tournaments.canStart(s.rules.NumPlayers); err == nil {
+			s.nextRound()
+		}
+	case CMD_TURN_TIMEOUT:
+		turn, _ := strconv.Atoi(command.args[1])
+		if r := s.room(command.args[0]); r != nil {
+			r.timeout(turn)
+		}
+	case CMD_BAN:
+		s.disconnect(sender, "> you have been banned by an admin")
+	case CMD_RELOAD:
+		// a match keeps its rules until it's over
+		if s.main.match != nil {
 			break
 		}
-		if len(cards) == 0 {
-			currentPlayerIdx = (currentPlayerIdx + 1) % g.NumPlayers
-			continue
+		if s.main.game.State == util.STATE_WAITING && s.main.resume == nil {
+			s.setRules(s.main, s.rules)
+		} else {
+			s.main.rules = s.rules
 		}
+	case CMD_LOGIN:
+		s.login(sender)
+	case CMD_DISCONNECTED:
+		log.Printf("client has disconnected: %s (%v)", sender.Nick, sender.Conn.RemoteAddr())
+		s.drop(sender)
+	}
+}
 
-		currentPlayerIdx = (currentPlayerIdx + 1) % g.NumPlayers
-		time.Sleep(500 * time.Millisecond)
+// GameLoop runs the games of the main room.
+func (s *server) GameLoop() error {
+	return s.main.loop()
+}
 
+func (s *server) showStats(c *client, args []string) {
+	nick := c.Nick
+	if len(args) > 1 {
+		nick = args[1]
 	}
-	log.Println("game ends")
-	return
+	ps, ok := s.stats.get(nick)
+	if !ok {
+		c.err(fmt.Errorf("> no games recorded for %s, only games of registered players are recorded", nick))
+		return
+	}
+	c.msg(MSG_MESSAGE, ps.String())
 }
 
 func (s *server) broadcast(msgType messageType, sender *client, msg string) {
-	s.members.Range(func(addr, member any) bool {
-		if sender != nil && addr == sender.Conn.RemoteAddr() {
+	s.members.Range(func(id, member any) bool {
+		if sender != nil && id == sender.ID {
 			return true
 		}
 		if member.(*client).Nick == "#anonymous" {
 			return true
 		}
 		member.(*client).msg(msgType, msg)
-		if s.game.State != util.STATE_PLAYING || (s.game.CurrentPlayer != nil && addr == s.game.CurrentPlayer.Conn.RemoteAddr()) {
-		}
 		return true
 	})
 }
@@ -275,184 +433,61 @@ func (s *server) listCommands(sender *client) {
    /view: view your current cards
    /use <card1> <card2> ...: use the cards you selected
    /pass: pass your current turn
+   /bid <points>: bid 1 to 3 points for becoming the landlord, 0 to pass
+   /queue [leave]: find a table with players of a similar rating
+   /rooms: list the rooms
+   /join <room or invite code>: move to another room
+   /create <name> [--private [invite code]]: create a room
+   /room: show the settings of your room, which its owner can change with
+      /room kick <nickname>, /room lock, /room unlock, /room owner <nickname>,
+      /room seats <number of players>, /room timer <duration>|off and
+      /room bidding on|off
+   /list: list the players in your room
+   /tournament [join|leave]: show the tournament or register for it
+   /match [<deals>|to <score>|off]: show or set up a match in your room
+   /stats [nickname]: show the statistics of a registered player
+   /leaderboard: show the players with the highest scores
    /quit: quit the game`
 	sender.msg(MSG_MESSAGE, msg)
 }
 
-func (s *server) listPlayers() []string {
-	var players []string
-	s.members.Range(func(_, c any) bool {
-		if c.(*client).Nick == "#anonymous" {
-			return true
-		}
-		switch s.game.State {
-		case util.STATE_WAITING:
-			if player, ok := s.game.Players.Load(c.(*client).Conn.RemoteAddr()); ok && player.(*util.Player).IsReady {
-				players = append(players, " - "+c.(*client).Nick+" (ready)")
-			} else {
-				players = append(players, " - "+c.(*client).Nick)
-			}
-		case util.STATE_PLAYING:
-			player, ok := s.game.Players.Load(c.(*client).Conn.RemoteAddr())
-			if ok {
-				playerStr := " -"
-				if s.game.CurrentPlayer != nil && player.(*util.Player).Conn.RemoteAddr() == s.game.CurrentPlayer.Conn.RemoteAddr() {
-					playerStr += ">"
-				}
-				playerStr += " " + c.(*client).Nick
-				if player.(*util.Player).Position == util.LANDLORD {
-					playerStr += " (landlord)"
-				}
-				players = append(players, playerStr)
-			}
-		case util.STATE_OVER:
-			players = append(players, c.(*client).Nick)
-		}
-		return true
-	})
-
-	return players
-}
-
-func (s *server) quit(c *client) {
-	defer c.Conn.Close()
-	c.msg(MSG_STOP, "> see you next time")
-	if ok := s.game.RemovePlayer(c.Conn); ok {
-		if s.game.State == util.STATE_PLAYING {
-			s.game.NextState()
-			s.broadcast(MSG_MESSAGE, c, fmt.Sprintf("> %s left the room, game ends", c.Nick))
-			s.game.CurrentUsedCards <- []*util.Card{}
-		} else {
-			s.broadcast(MSG_MESSAGE, c, fmt.Sprintf("> %s left the room", c.Nick))
-		}
-	} else {
-		s.broadcast(MSG_MESSAGE, c, fmt.Sprintf("> %s left the room", c.Nick))
+// displayName is the nickname followed by the rating for registered players.
+func (s *server) displayName(c *client) string {
+	if !c.Account {
+		return c.Nick
 	}
-	s.members.Delete(c.Conn.RemoteAddr())
-	log.Printf("client has disconnected: %s (%v)\n", c.Nick, c.Conn.RemoteAddr())
+	return fmt.Sprintf("%s [%d]", c.Nick, s.stats.ratingOf(c.Nick))
 }
 
-func (s *server) ready(c *client) {
-	s.game.AddPlayer(c.Conn, c.Nick)
-	player, _ := s.game.Players.Load(c.Conn.RemoteAddr())
-	if player.(*util.Player).IsReady {
-		c.err(errors.New("> you're already ready"))
-		return
-	}
-	player.(*util.Player).IsReady = true
-
-	c.msg(MSG_MESSAGE, fmt.Sprintf("> you are ready for the game. %v/%v", s.game.NumReady(), s.game.NumPlayers))
-
-	// c.prompt()
-	s.broadcast(MSG_MESSAGE, c, fmt.Sprintf("> %s is ready. %v/%v", c.Nick, s.game.NumReady(), s.game.NumPlayers))
-	s.broadcast(MSG_ROOM_INFO, nil, util.State(s.game.State)+"_"+strings.Join(s.listPlayers(), "\n"))
-	if s.game.NumReady() == s.game.NumPlayers {
-		s.broadcast(MSG_MESSAGE, nil, "> all players are ready. game will start soon...")
-		time.Sleep(1 * time.Second)
-	}
+func (s *server) quit(c *client) {
+	s.disconnect(c, "> see you next time")
 }
 
-func (s *server) viewCards(c *client, args []string) {
-	player, _ := s.game.Players.Load(c.Conn.RemoteAddr())
-	if _, ok := player.(*util.Player); !ok {
-		return
-	}
-	msg := player.(*util.Player).Highlight(s.game.LastUsedCards)
-	if player.(*util.Player).Position == util.LANDLORD {
-		msg = "landlord_" + msg
-	} else {
-		msg = "farmer_" + msg
-	}
-
-	c.msg(MSG_PLAYER_STATUS, msg)
+func (s *server) kick(c *client) {
+	s.disconnect(c, "> you have been kicked by an admin")
 }
 
-func (s *server) useCards(c *client, args []string) {
-	if s.game.CurrentPlayer.Conn.RemoteAddr() != c.Conn.RemoteAddr() {
-		c.err(errors.New("> it's not your turn"))
-		return
-	}
-	cardsString := args[1:]
-	var cards []*util.Card
-	var invalidCards []string
-	for _, s := range cardsString {
-		switch strings.ToUpper(s) {
-		case "A":
-			cards = append(cards, &util.Card{Point: util.ACE})
-		case "2":
-			cards = append(cards, &util.Card{Point: util.TWO})
-		case "3":
-			cards = append(cards, &util.Card{Point: util.THREE})
-		case "4":
-			cards = append(cards, &util.Card{Point: util.FOUR})
-		case "5":
-			cards = append(cards, &util.Card{Point: util.FIVE})
-		case "6":
-			cards = append(cards, &util.Card{Point: util.SIX})
-		case "7":
-			cards = append(cards, &util.Card{Point: util.SEVEN})
-		case "8":
-			cards = append(cards, &util.Card{Point: util.EIGHT})
-		case "9":
-			cards = append(cards, &util.Card{Point: util.NINE})
-		case "10":
-			cards = append(cards, &util.Card{Point: util.TEN})
-		case "J":
-			cards = append(cards, &util.Card{Point: util.JACK})
-		case "Q":
-			cards = append(cards, &util.Card{Point: util.QUEEN})
-		case "K":
-			cards = append(cards, &util.Card{Point: util.KING})
-		case "JOKER":
-			if s == "joker" {
-				cards = append(cards, &util.Card{Point: util.BLACK_JOKER})
-			} else {
-				cards = append(cards, &util.Card{Point: util.RED_JOKER})
-			}
-		default:
-			invalidCards = append(invalidCards, s)
-		}
-	}
-	if len(invalidCards) > 0 {
-		c.err(errors.New(fmt.Sprintf("> invalid cards: %v", invalidCards)))
-		cmd := <-s.commands
-		s.commands <- cmd
-		return
-	}
-	if len(cards) == 0 {
-		c.err(errors.New("> please select at least one card"))
-		cmd := <-s.commands
-		s.commands <- cmd
-		return
-	}
-	player, _ := s.game.Players.Load(c.Conn.RemoteAddr())
-	lastCards := s.game.LastUsedCards
-	err := player.(*util.Player).Use(cards, lastCards)
-	if err != nil {
-		c.err(err)
-	} else {
-		s.game.CurrentUsedCards <- cards
-		s.game.LastUsedCards = cards
-		s.game.LastPlayer = player.(*util.Player)
-		c.msg(MSG_MESSAGE, fmt.Sprintf("> you used the cards: %v", cards))
-		s.viewCards(c, []string{})
-		s.broadcast(MSG_MESSAGE, c, fmt.Sprintf("> %s used the cards: %v (%v remaining)", c.Nick, cards, len(s.game.CurrentPlayer.Cards)))
-	}
-
+func (s *server) disconnect(c *client, reason string) {
+	c.msg(MSG_STOP, reason)
+	log.Printf("client has disconnected: %s (%v)\n", c.Nick, c.Conn.RemoteAddr())
+	s.drop(c)
 }
 
-func (s *server) pass(c *client) {
-	if s.game.CurrentPlayer.Conn.RemoteAddr() != c.Conn.RemoteAddr() {
-		c.err(errors.New("> it's not your turn"))
-		return
+// drop removes c from the server and closes its connection once the
+// messages to c are written.
+func (s *server) drop(c *client) {
+	defer c.close()
+	s.queue.remove(c)
+	if c.room != nil {
+		c.room.leave(c)
 	}
-	c.msg(MSG_MESSAGE, "> you passed your turn")
-	s.broadcast(MSG_MESSAGE, c, fmt.Sprintf("> %s passed their turn", c.Nick))
-	s.game.CurrentUsedCards <- []*util.Card{}
+	s.members.Delete(c.ID)
 }
 
 func (s *server) SetNumPlayers(n int) {
-	s.game.NumPlayers = n
+	s.rules.NumPlayers = n
+	s.main.rules.NumPlayers = n
+	s.main.game.NumPlayers = n
 }
 
 func lenSyncMap(m *sync.Map) int {
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestConcurrentClients plays a game while another client chats and the
+// admins watch, which is meant to be run with -race.
+func TestConcurrentClients(t *testing.T) {
+	s, addr := testServer(t)
+	var players []*testClient
+	for _, nick := range []string{"alice", "bob", "carol"} {
+		players = append(players, dialTest(t, addr, nick))
+	}
+	watcher := dialTest(t, addr, "dave")
+
+	errs := make(chan error, len(players))
+	for _, c := range players {
+		c.send("/ready")
+		go func(c *testClient) {
+			errs <- c.play(time.Minute)
+		}(c)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-watcher.messages:
+			case <-time.After(50 * time.Millisecond):
+				watcher.send("hello")
+				watcher.send("/list")
+				watcher.send("/rooms")
+			}
+		}
+	}()
+	go func() {
+		handler := s.AdminHandler("secret")
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(20 * time.Millisecond):
+				handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/status?token=secret", nil))
+				s.writeMetrics(io.Discard)
+				s.console(io.Discard, "who")
+			}
+		}
+	}()
+
+	for range players {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	close(done)
+
+	players[0].send("/quit")
+	players[1].conn.Close()
+	watcher.conn.Close()
+	for _, c := range players[2:] {
+		c.send("/quit")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for lenSyncMap(&s.members) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected every client to be removed, %d left", lenSyncMap(&s.members))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.metrics.mu.Lock()
+	defer s.metrics.mu.Unlock()
+	if s.metrics.gamesCompleted != 1 {
+		t.Errorf("expected one completed game, got %d", s.metrics.gamesCompleted)
+	}
+}
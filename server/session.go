@@ -0,0 +1,20 @@
+package server
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"landlord/server/util"
+)
+
+// Every connection is the session of a player. The games know the players
+// by their player ID only, which the members of the server and the rooms map
+// to the clients and so to the connections of the players.
+
+var sessions atomic.Uint64
+
+// newPlayerID returns the ID of a new session, which is never reused while
+// the server is running.
+func newPlayerID() util.PlayerID {
+	return util.PlayerID(fmt.Sprintf("p%d", sessions.Add(1)))
+}
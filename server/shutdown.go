@@ -0,0 +1,170 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"landlord/server/util"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const SNAPSHOT_FILE = "game.json"
+
+// SHUTDOWN_TIMEOUT is the time the clients have to take the last messages
+const SHUTDOWN_TIMEOUT = 2 * time.Second
+
+// roomSnapshot is an interrupted game together with the room it was played in.
+type roomSnapshot struct {
+	Room    string         `json:"room"`
+	Matched bool           `json:"matched"`
+	Game    *util.Snapshot `json:"game"`
+	Match   *match         `json:"match,omitempty"`
+	Created bool           `json:"created,omitempty"`
+	Code    string         `json:"code,omitempty"`
+}
+
+func (s *server) snapshotPath() string {
+	return filepath.Join(s.dataDir, SNAPSHOT_FILE)
+}
+
+// Shutdown saves the games in progress, so that they can be continued after
+// a restart, and disconnects every member with reason.
+func (s *server) Shutdown(reason string) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var snaps []roomSnapshot
+	s.rooms.Range(func(_, value any) bool {
+		r := value.(*room)
+		snap := r.resume
+		g := r.game
+		if g.State == util.STATE_PLAYING && g.Landlord != nil && len(g.Order) == g.NumPlayers {
+			snap = g.Snapshot()
+		} else if g.State == util.STATE_PLAYING {
+			// the bids aren't saved, so the game starts over after the restart
+			r.broadcast(MSG_MESSAGE, nil, "> the landlord was still being chosen, this game can't be saved and is dropped")
+			log.Printf("the game in %s was dropped, the landlord was still being chosen", r.name)
+		}
+		if snap != nil {
+			snaps = append(snaps, roomSnapshot{r.name, r.matched, snap, r.match, r.created, r.code})
+		}
+		return true
+	})
+	if len(snaps) > 0 {
+		err = writeSnapshot(s.snapshotPath(), snaps)
+		if err != nil {
+			log.Printf("unable to save the games: %s", err.Error())
+		} else {
+			log.Printf("%d games saved to %s", len(snaps), s.snapshotPath())
+		}
+	}
+
+	msg := "> the server is shutting down: " + reason
+	if len(snaps) > 0 && err == nil {
+		msg += "\n  the games were saved, reconnect with the same nickname to continue them"
+	}
+	var clients []*client
+	s.members.Range(func(id, member any) bool {
+		c := member.(*client)
+		if c.Nick != "#anonymous" {
+			c.msg(MSG_STOP, msg)
+		}
+		c.close()
+		s.members.Delete(id)
+		clients = append(clients, c)
+		return true
+	})
+	// give the clients a moment to take their last messages
+	timeout := time.After(SHUTDOWN_TIMEOUT)
+	for _, c := range clients {
+		select {
+		case <-c.written:
+		case <-timeout:
+			return
+		}
+	}
+	return
+}
+
+// Restore reopens the tables of a running tournament and loads the games
+// saved by Shutdown. Their players take their seats as they reconnect and
+// each game continues once all of its players are back.
+func (s *server) Restore() error {
+	s.resumeTournament()
+	byts, err := os.ReadFile(s.snapshotPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var snaps []roomSnapshot
+	if err := json.Unmarshal(byts, &snaps); err != nil {
+		// saved by a version with a single room
+		var snap util.Snapshot
+		if err := json.Unmarshal(byts, &snap); err != nil {
+			return err
+		}
+		snaps = []roomSnapshot{{Room: MAIN_ROOM, Game: &snap}}
+	}
+	for _, rs := range snaps {
+		snap := rs.Game
+		if snap == nil {
+			return fmt.Errorf("invalid snapshot of %s: no game", rs.Room)
+		}
+		if err := snap.Check(); err != nil {
+			return fmt.Errorf("invalid snapshot of %s: %w", rs.Room, err)
+		}
+		r := s.room(rs.Room)
+		if r == nil {
+			r = s.newRoom(rs.Room, s.rules)
+			r.matched = rs.Matched
+			r.created = rs.Created
+			r.code = rs.Code
+			go r.loop()
+		}
+		r.resume = snap
+		r.match = rs.Match
+		r.game.NumPlayers = snap.NumPlayers
+		log.Printf("restored an interrupted game in %s, waiting for %d players", r.name, snap.NumPlayers)
+	}
+	return os.Remove(s.snapshotPath())
+}
+
+// restore continues the interrupted game once all of its players are
+// seated. It returns the turn of the current player.
+func (r *room) restore() ([]util.Event, error) {
+	g := r.game
+	snap := r.resume
+	r.resume = nil
+	if _, err := g.Restore(snap); err != nil {
+		return nil, fmt.Errorf("unable to continue the interrupted game: %w", err)
+	}
+	if err := g.CheckCards(); err != nil {
+		return nil, fmt.Errorf("unable to continue the interrupted game: %w", err)
+	}
+	r.broadcast(MSG_MESSAGE, nil, "> all players are back, the interrupted game continues")
+	for _, player := range g.Order {
+		if c := r.client(player); c != nil {
+			r.viewCards(c, []string{})
+		}
+	}
+	return []util.Event{g.Turn()}, nil
+}
+
+func writeSnapshot(path string, snaps []roomSnapshot) error {
+	byts, err := json.MarshalIndent(snaps, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, byts, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"landlord/config"
+)
+
+func TestShutdownDropsBidding(t *testing.T) {
+	s, addr := testServer(t, func(cfg *config.Config) { cfg.Rules.Bidding = true })
+	landlordDeck(t)(s)
+	alice, bob, carol := dialTest(t, addr, "alice"), dialTest(t, addr, "bob"), dialTest(t, addr, "carol")
+	for i, c := range []*testClient{alice, bob, carol} {
+		c.send("/ready")
+		c.expect("you are ready for the game at seat " + string(rune('1'+i)))
+	}
+	alice.expect("bid for becoming the landlord")
+
+	if err := s.Shutdown("test"); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*testClient{alice, bob, carol} {
+		c.expect("> the landlord was still being chosen, this game can't be saved and is dropped")
+		c.expect("> the server is shutting down: test")
+	}
+	if _, err := os.Stat(s.snapshotPath()); !os.IsNotExist(err) {
+		t.Errorf("expected no game to be saved: %v", err)
+	}
+}
+
+func TestRestoreCorrupt(t *testing.T) {
+	s, _ := testServer(t)
+	game := `{"num_players":3,"current":0,"last_player":-1,"seats":[
+		{"nick":"alice","landlord":true,"cards":["♠3"]},
+		{"nick":"bob","cards":["♠4"]},
+		{"nick":"carol","cards":[{"Point":99,"Color":0}]}]}`
+	if err := os.WriteFile(s.snapshotPath(), []byte(`[{"room":"main","game":`+game+`}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	err := s.Restore()
+	if err == nil || !strings.Contains(err.Error(), "invalid card") {
+		t.Fatalf("expected the invalid card to be found, got %v", err)
+	}
+}
+
+func TestShutdownRestore(t *testing.T) {
+	dir := t.TempDir()
+	s, addr := testServer(t, func(cfg *config.Config) { cfg.DataDir = dir })
+	landlordDeck(t)(s)
+	alice, bob, carol := dialTest(t, addr, "alice"), dialTest(t, addr, "bob"), dialTest(t, addr, "carol")
+	seatAll(alice, bob, carol)
+	alice.expect("it's your turn")
+	alice.send("/use 4 4")
+	alice.expect("> you used the cards")
+	bob.expect("it's your turn")
+
+	if err := s.Shutdown("restart"); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*testClient{alice, bob, carol} {
+		c.expect("the games were saved")
+	}
+
+	s, addr = testServer(t, func(cfg *config.Config) { cfg.DataDir = dir })
+	s.mu.Lock()
+	err := s.Restore()
+	s.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, bob, carol = dialTest(t, addr, "alice"), dialTest(t, addr, "bob"), dialTest(t, addr, "carol")
+	for _, c := range []*testClient{alice, bob, carol} {
+		c.expect("> all players are back, the interrupted game continues")
+	}
+	bob.expect("you have to beat [♥4 ♠4] from alice")
+}
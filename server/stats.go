@@ -0,0 +1,239 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"landlord/server/util"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	STATS_FILE       = "stats.json"
+	RESULTS_FILE     = "results.jsonl"
+	LEADERBOARD_SIZE = 10
+)
+
+type ratingPoint struct {
+	Time   time.Time `json:"time"`
+	Rating float64   `json:"rating"`
+}
+
+type playerStats struct {
+	Nick          string        `json:"nick"`
+	GamesPlayed   int           `json:"games_played"`
+	LandlordWins  int           `json:"landlord_wins"`
+	FarmerWins    int           `json:"farmer_wins"`
+	Score         int           `json:"score"`
+	Bombs         int           `json:"bombs"`
+	Springs       int           `json:"springs"`
+	TotalTime     time.Duration `json:"total_time"`
+	Rating        float64       `json:"rating"`
+	RatingHistory []ratingPoint `json:"rating_history"`
+}
+
+func (ps *playerStats) averageTime() time.Duration {
+	if ps.GamesPlayed == 0 {
+		return 0
+	}
+	return (ps.TotalTime / time.Duration(ps.GamesPlayed)).Round(time.Second)
+}
+
+func (ps *playerStats) ratingString() string {
+	rating := fmt.Sprintf("%.0f", ps.Rating)
+	if n := len(ps.RatingHistory); n > 1 {
+		rating += fmt.Sprintf(" (%+.0f in the last game)", ps.RatingHistory[n-1].Rating-ps.RatingHistory[n-2].Rating)
+	}
+	return rating
+}
+
+func (ps *playerStats) String() string {
+	return fmt.Sprintf("> stats for %s:\n"+
+		"   rating: %s\n"+
+		"   games played: %d (won %d as landlord, %d as farmer)\n"+
+		"   total score: %d\n"+
+		"   bombs played: %d\n"+
+		"   springs: %d\n"+
+		"   average game: %v",
+		ps.Nick, ps.ratingString(), ps.GamesPlayed, ps.LandlordWins, ps.FarmerWins, ps.Score,
+		ps.Bombs, ps.Springs, ps.averageTime())
+}
+
+func newPlayerStats(nick string) *playerStats {
+	return &playerStats{
+		Nick:          nick,
+		Rating:        util.INITIAL_RATING,
+		RatingHistory: []ratingPoint{{time.Now(), util.INITIAL_RATING}},
+	}
+}
+
+// stats aggregates the results of finished games per account. Every result
+// is also appended to the results file in the data directory.
+type stats struct {
+	mu      sync.Mutex
+	dir     string
+	players map[string]*playerStats
+}
+
+func loadStats(dir string) (*stats, error) {
+	st := &stats{dir: dir, players: make(map[string]*playerStats)}
+	byts, err := os.ReadFile(filepath.Join(dir, STATS_FILE))
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	var players []*playerStats
+	if err := json.Unmarshal(byts, &players); err != nil {
+		return st, fmt.Errorf("unable to parse %s: %w", STATS_FILE, err)
+	}
+	for _, ps := range players {
+		if ps.Rating == 0 {
+			ps.Rating = util.INITIAL_RATING
+		}
+		st.players[strings.ToLower(ps.Nick)] = ps
+	}
+	return st, nil
+}
+
+// record stores result and adds it to the stats of the given accounts.
+// Guests take part in the rating update with the initial rating, but only
+// the ratings of accounts are kept.
+func (st *stats) record(result util.Result, accounts []string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	var farmers []float64
+	for nick := range result.Scores {
+		if nick != result.Landlord {
+			farmers = append(farmers, st.rating(nick))
+		}
+	}
+	change := util.RatingChange(st.rating(result.Landlord), farmers, result.LandlordWon)
+
+	for _, nick := range accounts {
+		ps, ok := st.players[strings.ToLower(nick)]
+		if !ok {
+			ps = newPlayerStats(nick)
+			st.players[strings.ToLower(nick)] = ps
+		}
+		if nick == result.Landlord {
+			ps.Rating += change
+		} else {
+			// the farmers share the loss of the landlord, so that the sum
+			// of the ratings stays the same
+			ps.Rating -= change / float64(len(farmers))
+		}
+		ps.RatingHistory = append(ps.RatingHistory, ratingPoint{time.Now(), ps.Rating})
+		ps.GamesPlayed++
+		ps.Score += result.Scores[nick]
+		ps.Bombs += result.Bombs[nick]
+		ps.TotalTime += result.Duration
+		landlord := nick == result.Landlord
+		if landlord && result.LandlordWon {
+			ps.LandlordWins++
+		}
+		if !landlord && !result.LandlordWon {
+			ps.FarmerWins++
+		}
+		if result.Spring && landlord == result.LandlordWon {
+			ps.Springs++
+		}
+	}
+
+	if err := os.MkdirAll(st.dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(st.dir, RESULTS_FILE), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := json.NewEncoder(f).Encode(result); err != nil {
+		return err
+	}
+	return st.save()
+}
+
+func (st *stats) save() error {
+	var players []*playerStats
+	for _, ps := range st.players {
+		players = append(players, ps)
+	}
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].Nick < players[j].Nick
+	})
+	byts, err := json.MarshalIndent(players, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(st.dir, STATS_FILE)
+	if err := os.WriteFile(path+".tmp", byts, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// rating returns the current rating of nick, which has to be called with
+// st.mu held.
+func (st *stats) rating(nick string) float64 {
+	if ps, ok := st.players[strings.ToLower(nick)]; ok {
+		return ps.Rating
+	}
+	return util.INITIAL_RATING
+}
+
+// ratingOf returns the rounded rating of nick.
+func (st *stats) ratingOf(nick string) int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return int(math.Round(st.rating(nick)))
+}
+
+func (st *stats) get(nick string) (playerStats, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	ps, ok := st.players[strings.ToLower(nick)]
+	if !ok {
+		return playerStats{}, false
+	}
+	return *ps, true
+}
+
+// leaderboard returns the players with the highest total score.
+func (st *stats) leaderboard(n int) []playerStats {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var players []playerStats
+	for _, ps := range st.players {
+		players = append(players, *ps)
+	}
+	sort.Slice(players, func(i, j int) bool {
+		if players[i].Score != players[j].Score {
+			return players[i].Score > players[j].Score
+		}
+		return players[i].Nick < players[j].Nick
+	})
+	if len(players) > n {
+		players = players[:n]
+	}
+	return players
+}
+
+func (st *stats) leaderboardString() string {
+	var lines []string
+	for i, ps := range st.leaderboard(LEADERBOARD_SIZE) {
+		lines = append(lines, fmt.Sprintf("%2d. %-16s %5d (%d games, rating %.0f)", i+1, ps.Nick, ps.Score, ps.GamesPlayed, ps.Rating))
+	}
+	if len(lines) == 0 {
+		return "no games recorded yet"
+	}
+	return strings.Join(lines, "\n")
+}
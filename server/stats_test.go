@@ -0,0 +1,33 @@
+package server
+
+import (
+	"math"
+	"testing"
+
+	"landlord/server/util"
+)
+
+func TestRatingsSum(t *testing.T) {
+	st, err := loadStats(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	nicks := []string{"alice", "bob", "carol"}
+	for i := 0; i < 10; i++ {
+		result := util.Result{
+			Landlord:    nicks[i%3],
+			LandlordWon: i%4 != 0,
+			Scores:      map[string]int{"alice": 0, "bob": 0, "carol": 0},
+		}
+		if err := st.record(result, nicks); err != nil {
+			t.Fatal(err)
+		}
+		sum := 0.0
+		for _, nick := range nicks {
+			sum += st.rating(nick)
+		}
+		if math.Abs(sum-3*util.INITIAL_RATING) > 1e-9 {
+			t.Fatalf("expected the ratings to add up to %d after game %d, got %v", 3*util.INITIAL_RATING, i+1, sum)
+		}
+	}
+}
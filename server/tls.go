@@ -0,0 +1,112 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"landlord/config"
+)
+
+const (
+	SELF_SIGNED_CERT = "tls-cert.pem"
+	SELF_SIGNED_KEY  = "tls-key.pem"
+	// SELF_SIGNED_VALIDITY is how long a generated certificate is valid
+	SELF_SIGNED_VALIDITY = 365 * 24 * time.Hour
+	// TLS_HANDSHAKE_TIMEOUT is the time a client has to finish the handshake
+	TLS_HANDSHAKE_TIMEOUT = 10 * time.Second
+)
+
+// TLSConfig returns the tls config of the game server. Without a certificate
+// in cfg, the self-signed certificate in dataDir is used, which is generated
+// for hosts on first use. Clients can trust it with --ca.
+func TLSConfig(cfg config.TLS, dataDir string, hosts ...string) (*tls.Config, error) {
+	if cfg.Cert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+		if err != nil {
+			return nil, err
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+	}
+
+	certFile := filepath.Join(dataDir, SELF_SIGNED_CERT)
+	keyFile := filepath.Join(dataDir, SELF_SIGNED_KEY)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		var certPEM, keyPEM []byte
+		if certPEM, keyPEM, err = SelfSignedCert(hosts...); err != nil {
+			return nil, err
+		}
+		if err = os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+			return nil, err
+		}
+		if err = os.WriteFile(certFile, certPEM, 0644); err != nil {
+			return nil, err
+		}
+		log.Printf("generated a self-signed certificate in %s", certFile)
+		cert, err = tls.X509KeyPair(certPEM, keyPEM)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("tls certificate fingerprint: sha256:%x", sha256.Sum256(cert.Certificate[0]))
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// SelfSignedCert generates a certificate and key for hosts, which are host
+// names or IP addresses, along with localhost. Both are PEM encoded.
+func SelfSignedCert(hosts ...string) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return
+	}
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"landlord"}, CommonName: "landlord self-signed"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(SELF_SIGNED_VALIDITY),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			if !ip.IsUnspecified() && !ip.IsLoopback() {
+				template.IPAddresses = append(template.IPAddresses, ip)
+			}
+		} else if host != "" && host != "localhost" {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
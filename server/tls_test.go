@@ -0,0 +1,57 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"landlord/config"
+)
+
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	first, err := TLSConfig(config.TLS{Enabled: true}, dir, "0.0.0.0", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(first.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := leaf.VerifyHostname("example.com"); err != nil {
+		t.Error(err)
+	}
+	if err := leaf.VerifyHostname("127.0.0.1"); err != nil {
+		t.Error(err)
+	}
+
+	second, err := TLSConfig(config.TLS{Enabled: true}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Certificates[0].Certificate[0], second.Certificates[0].Certificate[0]) {
+		t.Error("expected the generated certificate to be reused")
+	}
+
+	certPEM, keyPEM, err := SelfSignedCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.TLS{Enabled: true, Cert: filepath.Join(dir, "cert.pem"), Key: filepath.Join(dir, "key.pem")}
+	os.WriteFile(cfg.Cert, certPEM, 0644)
+	os.WriteFile(cfg.Key, keyPEM, 0600)
+	given, err := TLSConfig(cfg, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, _ := tls.X509KeyPair(certPEM, keyPEM)
+	if !bytes.Equal(given.Certificates[0].Certificate[0], pair.Certificate[0]) {
+		t.Error("expected the configured certificate to be used")
+	}
+	if _, err := TLSConfig(config.TLS{Enabled: true, Cert: cfg.Cert, Key: cfg.Cert}, dir); err == nil {
+		t.Error("expected an invalid key to be rejected")
+	}
+}
@@ -0,0 +1,637 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+
+	"landlord/server/util"
+)
+
+const (
+	TOURNAMENT_FILE = "tournament.json"
+	// finished tournaments are archived in TOURNAMENT_DIR by name
+	TOURNAMENT_DIR = "tournaments"
+
+	TOURNAMENT_REGISTERING = "registering"
+	TOURNAMENT_RUNNING     = "running"
+	TOURNAMENT_FINISHED    = "finished"
+
+	// TABLE_TIMEOUT is the time the players of a tournament table have to
+	// take their seats for a deal, unless the rules have a turn timeout.
+	// The players who are still away then forfeit the deal.
+	TABLE_TIMEOUT = 5 * time.Minute
+)
+
+var ErrNoTournament = errors.New("no tournament")
+
+type tournamentTable struct {
+	Room   string         `json:"room"`
+	Seats  []string       `json:"seats"`
+	Played int            `json:"played"`
+	Scores map[string]int `json:"scores"`
+	Wins   map[string]int `json:"wins"`
+}
+
+type tournamentRound struct {
+	Number    int                `json:"number"`
+	Tables    []*tournamentTable `json:"tables"`
+	Byes      []string           `json:"byes"`
+	Standings []standing         `json:"standings,omitempty"`
+}
+
+type standing struct {
+	Nick  string `json:"nick"`
+	Score int    `json:"score"`
+	Deals int    `json:"deals"`
+	Wins  int    `json:"wins"`
+	Byes  int    `json:"byes"`
+}
+
+type tournament struct {
+	Name     string             `json:"name"`
+	Rounds   int                `json:"rounds"`
+	Deals    int                `json:"deals"`
+	Players  []string           `json:"players"`
+	State    string             `json:"state"`
+	History  []*tournamentRound `json:"history"`
+	Created  time.Time          `json:"created"`
+	Finished time.Time          `json:"finished,omitempty"`
+}
+
+func (t *tournament) round() *tournamentRound {
+	if len(t.History) == 0 {
+		return nil
+	}
+	return t.History[len(t.History)-1]
+}
+
+// standings adds up the results of every round played so far.
+func (t *tournament) standings() []standing {
+	byNick := make(map[string]*standing)
+	for _, nick := range t.Players {
+		byNick[nick] = &standing{Nick: nick}
+	}
+	for _, round := range t.History {
+		for _, table := range round.Tables {
+			for _, nick := range table.Seats {
+				byNick[nick].Score += table.Scores[nick]
+				byNick[nick].Wins += table.Wins[nick]
+				byNick[nick].Deals += table.Played
+			}
+		}
+		for _, nick := range round.Byes {
+			byNick[nick].Byes++
+		}
+	}
+	var standings []standing
+	for _, nick := range t.Players {
+		standings = append(standings, *byNick[nick])
+	}
+	sort.SliceStable(standings, func(i, j int) bool {
+		if standings[i].Score != standings[j].Score {
+			return standings[i].Score > standings[j].Score
+		}
+		return standings[i].Wins > standings[j].Wins
+	})
+	return standings
+}
+
+// seat plans the next round with tables of size.
+func (t *tournament) seat(size int) *tournamentRound {
+	byes := make(map[string]int)
+	for _, round := range t.History {
+		for _, nick := range round.Byes {
+			byes[nick]++
+		}
+	}
+	number := len(t.History) + 1
+	seats, out := seatRound(t.Players, number-1, size, byes)
+	round := &tournamentRound{Number: number, Byes: out}
+	for i, table := range seats {
+		round.Tables = append(round.Tables, &tournamentTable{
+			Room:   fmt.Sprintf("%s-r%d-t%d", t.Name, number, i+1),
+			Seats:  table,
+			Scores: make(map[string]int),
+			Wins:   make(map[string]int),
+		})
+	}
+	t.History = append(t.History, round)
+	return round
+}
+
+// seatRound splits players into tables of size for the given round, counted
+// from 0. If the tables can't be filled, the players who have sat out the
+// fewest rounds sit out. The others are laid out in a grid with a row per
+// seat, whose rows are shifted against each other every round so that
+// opponents change, and the seats rotate at every table.
+func seatRound(players []string, round, size int, byes map[string]int) (tables [][]string, out []string) {
+	if size <= 0 || len(players) < size {
+		return nil, players
+	}
+	extra := len(players) % size
+	sitting := make(map[string]bool)
+	if extra > 0 {
+		order := make([]string, len(players))
+		for i := range players {
+			order[i] = players[(i+round*extra)%len(players)]
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return byes[order[i]] < byes[order[j]]
+		})
+		for _, nick := range order[:extra] {
+			sitting[nick] = true
+		}
+	}
+	var rest []string
+	for _, nick := range players {
+		if sitting[nick] {
+			out = append(out, nick)
+		} else {
+			rest = append(rest, nick)
+		}
+	}
+
+	m := len(rest) / size
+	for t := 0; t < m; t++ {
+		table := make([]string, size)
+		for row := 0; row < size; row++ {
+			col := (t + row*round) % m
+			table[(row+round)%size] = rest[row*m+col]
+		}
+		tables = append(tables, table)
+	}
+	return
+}
+
+// tournaments holds the current tournament, which is saved to the data
+// directory after every change.
+type tournaments struct {
+	mu      sync.Mutex
+	dir     string
+	current *tournament
+}
+
+func loadTournaments(dir string) (*tournaments, error) {
+	ts := &tournaments{dir: dir}
+	byts, err := os.ReadFile(filepath.Join(dir, TOURNAMENT_FILE))
+	if errors.Is(err, os.ErrNotExist) {
+		return ts, nil
+	}
+	if err != nil {
+		return ts, err
+	}
+	var t tournament
+	if err := json.Unmarshal(byts, &t); err != nil {
+		return ts, fmt.Errorf("unable to parse %s: %w", TOURNAMENT_FILE, err)
+	}
+	ts.current = &t
+	return ts, nil
+}
+
+func (ts *tournaments) save() error {
+	t := ts.current
+	if t == nil {
+		return nil
+	}
+	path := filepath.Join(ts.dir, TOURNAMENT_FILE)
+	if t.State == TOURNAMENT_FINISHED {
+		path = filepath.Join(ts.dir, TOURNAMENT_DIR, t.Name+".json")
+	}
+	byts, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", byts, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	if t.State == TOURNAMENT_FINISHED {
+		err = os.Remove(filepath.Join(ts.dir, TOURNAMENT_FILE))
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+	}
+	return err
+}
+
+func (ts *tournaments) open(name string, rounds, deals int) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.current != nil && ts.current.State != TOURNAMENT_FINISHED {
+		return fmt.Errorf("the tournament %s is %s", ts.current.Name, ts.current.State)
+	}
+	if err := validNick(name); err != nil {
+		return fmt.Errorf("invalid name: %w", err)
+	}
+	if rounds <= 0 || deals <= 0 {
+		return errors.New("rounds and deals must be positive")
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, TOURNAMENT_DIR, name+".json")); err == nil {
+		return fmt.Errorf("a tournament named %s has already been played", name)
+	}
+	ts.current = &tournament{
+		Name:    name,
+		Rounds:  rounds,
+		Deals:   deals,
+		Players: []string{},
+		State:   TOURNAMENT_REGISTERING,
+		Created: time.Now(),
+	}
+	return ts.save()
+}
+
+func (ts *tournaments) join(nick string) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	t := ts.current
+	if t == nil || t.State != TOURNAMENT_REGISTERING {
+		return errors.New("no tournament is open for registration")
+	}
+	for _, player := range t.Players {
+		if strings.EqualFold(player, nick) {
+			return errors.New("you're already registered")
+		}
+	}
+	t.Players = append(t.Players, nick)
+	return ts.save()
+}
+
+func (ts *tournaments) leave(nick string) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	t := ts.current
+	if t == nil || t.State != TOURNAMENT_REGISTERING {
+		return errors.New("no tournament is open for registration")
+	}
+	for i, player := range t.Players {
+		if strings.EqualFold(player, nick) {
+			t.Players = append(t.Players[:i], t.Players[i+1:]...)
+			return ts.save()
+		}
+	}
+	return errors.New("you're not registered")
+}
+
+// canStart reports why the current tournament can't start with tables of
+// size, if it can't.
+func (ts *tournaments) canStart(size int) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	t := ts.current
+	if t == nil || t.State != TOURNAMENT_REGISTERING {
+		return errors.New("no tournament is open for registration")
+	}
+	if len(t.Players) < size {
+		return fmt.Errorf("at least %d players have to register, %d did", size, len(t.Players))
+	}
+	return nil
+}
+
+// next seats the next round of the current tournament, or finishes it after
+// the last round, in which case round is nil. The finished round, if any,
+// carries the standings after it.
+func (ts *tournaments) next(size int) (round, finished *tournamentRound, err error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	t := ts.current
+	if t == nil {
+		return nil, nil, ErrNoTournament
+	}
+	if t.State == TOURNAMENT_REGISTERING {
+		t.State = TOURNAMENT_RUNNING
+	}
+	if finished = t.round(); finished != nil {
+		finished.Standings = t.standings()
+	}
+	if len(t.History) == t.Rounds {
+		t.State = TOURNAMENT_FINISHED
+		t.Finished = time.Now()
+		return nil, finished, ts.save()
+	}
+	round = t.seat(size)
+	return round, finished, ts.save()
+}
+
+func (ts *tournaments) record(table *tournamentTable, result util.Result) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	table.Played++
+	for _, nick := range table.Seats {
+		table.Scores[nick] += result.Scores[nick]
+		if result.Scores[nick] > 0 {
+			table.Wins[nick]++
+		}
+	}
+	return ts.save()
+}
+
+func (ts *tournaments) deals() int {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.current == nil {
+		return 0
+	}
+	return ts.current.Deals
+}
+
+func (ts *tournaments) tableDone(table *tournamentTable) bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.current != nil && table.Played >= ts.current.Deals
+}
+
+// roundDone reports whether every table of the current round has played
+// all of its deals.
+func (ts *tournaments) roundDone() bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	t := ts.current
+	if t == nil || t.State != TOURNAMENT_RUNNING || t.round() == nil {
+		return false
+	}
+	for _, table := range t.round().Tables {
+		if table.Played < t.Deals {
+			return false
+		}
+	}
+	return true
+}
+
+// snapshot returns a copy of the current tournament.
+func (ts *tournaments) snapshot() (tournament, bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.current == nil {
+		return tournament{}, false
+	}
+	var t tournament
+	byts, _ := json.Marshal(ts.current)
+	json.Unmarshal(byts, &t)
+	return t, true
+}
+
+// find returns the current or an archived tournament.
+func (ts *tournaments) find(name string) (tournament, error) {
+	t, ok := ts.snapshot()
+	if ok && (name == "" || name == t.Name) {
+		return t, nil
+	}
+	if name == "" || validNick(name) != nil {
+		return t, ErrNoTournament
+	}
+	byts, err := os.ReadFile(filepath.Join(ts.dir, TOURNAMENT_DIR, name+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return t, ErrNoTournament
+	}
+	if err != nil {
+		return t, err
+	}
+	err = json.Unmarshal(byts, &t)
+	return t, err
+}
+
+// table returns the table of the current round that nick is seated at and
+// hasn't finished yet.
+func (ts *tournaments) table(nick string) *tournamentTable {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	t := ts.current
+	if t == nil || t.State != TOURNAMENT_RUNNING || t.round() == nil {
+		return nil
+	}
+	for _, table := range t.round().Tables {
+		for _, seat := range table.Seats {
+			if strings.EqualFold(seat, nick) && table.Played < t.Deals {
+				return table
+			}
+		}
+	}
+	return nil
+}
+
+func (ts *tournaments) tables() []*tournamentTable {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	t := ts.current
+	if t == nil || t.State != TOURNAMENT_RUNNING || t.round() == nil {
+		return nil
+	}
+	return t.round().Tables
+}
+
+func standingsString(standings []standing) string {
+	var lines []string
+	for i, st := range standings {
+		lines = append(lines, fmt.Sprintf("%2d. %-16s %5d (%d deals, %d won)", i+1, st.Nick, st.Score, st.Deals, st.Wins))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func writeStandingsCSV(w io.Writer, t tournament) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"rank", "nick", "score", "deals", "wins", "byes"})
+	for i, st := range t.standings() {
+		cw.Write([]string{strconv.Itoa(i + 1), st.Nick, strconv.Itoa(st.Score),
+			strconv.Itoa(st.Deals), strconv.Itoa(st.Wins), strconv.Itoa(st.Byes)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (s *server) tournamentCommand(c *client, args []string) {
+	action := ""
+	if len(args) > 1 {
+		action = args[1]
+	}
+	switch action {
+	case "join":
+		if !c.Account {
+			c.err(errors.New("> only registered players can take part in tournaments"))
+			return
+		}
+		if err := s.tournaments.join(c.Nick); err != nil {
+			c.err(errors.New("> " + err.Error()))
+			return
+		}
+		c.msg(MSG_MESSAGE, "> you're registered for the tournament")
+	case "leave":
+		if err := s.tournaments.leave(c.Nick); err != nil {
+			c.err(errors.New("> " + err.Error()))
+			return
+		}
+		c.msg(MSG_MESSAGE, "> you left the tournament")
+	case "":
+		t, ok := s.tournaments.snapshot()
+		if !ok {
+			c.err(errors.New("> there is no tournament"))
+			return
+		}
+		msg := fmt.Sprintf("> tournament %s (%s), %d rounds of %d deals", t.Name, t.State, t.Rounds, t.Deals)
+		if t.State == TOURNAMENT_REGISTERING {
+			msg += fmt.Sprintf("\n  %d players registered: %s\n  type /tournament join to take part",
+				len(t.Players), strings.Join(t.Players, ", "))
+		} else {
+			msg += fmt.Sprintf(", round %d\n", len(t.History)) + standingsString(t.standings())
+		}
+		c.msg(MSG_MESSAGE, msg)
+	default:
+		c.err(errors.New("> usage: /tournament [join|leave]"))
+	}
+}
+
+// nextRound starts the next round of the tournament, or announces the final
+// standings after the last one.
+func (s *server) nextRound() {
+	round, finished, err := s.tournaments.next(s.rules.NumPlayers)
+	if errors.Is(err, ErrNoTournament) {
+		return
+	}
+	if err != nil {
+		log.Printf("unable to save the tournament: %s", err.Error())
+	}
+	t, _ := s.tournaments.snapshot()
+	if finished != nil {
+		s.broadcast(MSG_MESSAGE, nil, fmt.Sprintf("> [tournament] standings after round %d:\n%s",
+			finished.Number, standingsString(finished.Standings)))
+	}
+	if round == nil {
+		if finished == nil || len(finished.Standings) == 0 {
+			log.Printf("tournament %s finished without players", t.Name)
+			s.broadcast(MSG_MESSAGE, nil, fmt.Sprintf("> [tournament] %s is over", t.Name))
+			return
+		}
+		winner := finished.Standings[0].Nick
+		log.Printf("tournament %s finished, won by %s", t.Name, winner)
+		s.broadcast(MSG_MESSAGE, nil, fmt.Sprintf("> [tournament] %s is over, congratulations to %s!", t.Name, winner))
+		return
+	}
+
+	log.Printf("tournament %s: round %d started with %d tables", t.Name, round.Number, len(round.Tables))
+	s.broadcast(MSG_MESSAGE, nil, fmt.Sprintf("> [tournament] round %d of %s starts", round.Number, t.Name))
+	for _, table := range round.Tables {
+		s.openTable(table)
+	}
+	for _, nick := range round.Byes {
+		if c := s.findClient(nick); c != nil {
+			c.msg(MSG_MESSAGE, "> [tournament] you sit out this round")
+		}
+	}
+}
+
+// openTable creates the room of a tournament table and seats the players who
+// are online.
+func (s *server) openTable(table *tournamentTable) *room {
+	r := s.room(table.Room)
+	if r == nil {
+		rules := s.rules
+		rules.NumPlayers = len(table.Seats)
+		r = s.newRoom(table.Room, rules)
+		r.waiting = time.Now()
+		go r.loop()
+	}
+	r.matched = true
+	r.table = table
+	for _, nick := range table.Seats {
+		c := s.findClient(nick)
+		if c == nil || c.room == r {
+			continue
+		}
+		if c.room != nil && c.room.playing(c) {
+			// leaving would end the game for the others
+			c.msg(MSG_MESSAGE, "> [tournament] your table is ready, you take your seat once your game ends")
+			continue
+		}
+		s.takeTableSeat(c, r)
+	}
+	return r
+}
+
+// forfeitAbsent ends the deal of a tournament table that has waited too long
+// for its players. The players who are offline or went to another room lose
+// the deal, while the ones still playing a game elsewhere are waited for.
+func (r *room) forfeitAbsent() {
+	timeout := r.rules.TurnTimeout.Duration()
+	if timeout <= 0 {
+		timeout = TABLE_TIMEOUT
+	}
+	if r.game.State != util.STATE_WAITING || time.Since(r.waiting) < timeout {
+		return
+	}
+	var absent []string
+	for _, nick := range r.table.Seats {
+		if c := r.s.findClient(nick); c == nil || (c.room != r && !c.room.playing(c)) {
+			absent = append(absent, nick)
+		}
+	}
+	if len(absent) == 0 {
+		return
+	}
+	if r.resume != nil {
+		r.resume = nil
+		r.broadcast(MSG_MESSAGE, nil, "> the interrupted game is given up")
+	}
+	r.forfeit(absent)
+	r.game.State = util.STATE_OVER
+}
+
+// forfeit counts the deal of the tournament table as lost by the absent
+// players, who lose a point to every other player.
+func (r *room) forfeit(absent []string) {
+	result := util.Result{Scores: make(map[string]int)}
+	for _, nick := range r.table.Seats {
+		if slices.Contains(absent, nick) {
+			result.Scores[nick] = -(len(r.table.Seats) - len(absent))
+		} else {
+			result.Scores[nick] = len(absent)
+		}
+	}
+	log.Printf("%s forfeit a deal in %s", strings.Join(absent, ", "), r.name)
+	r.broadcast(MSG_MESSAGE, nil, fmt.Sprintf("> [tournament] %s forfeit the deal", strings.Join(absent, ", ")))
+	if err := r.s.tournaments.record(r.table, result); err != nil {
+		log.Printf("unable to save the tournament: %s", err.Error())
+	}
+}
+
+// tableRoom returns the room of the tournament table of c, if it is open.
+func (s *server) tableRoom(c *client) *room {
+	if table := s.tournaments.table(c.Nick); c.Account && table != nil {
+		return s.room(table.Room)
+	}
+	return nil
+}
+
+// takeTableSeat moves c to the room of its tournament table and seats it.
+func (s *server) takeTableSeat(c *client, r *room) {
+	s.queue.remove(c)
+	s.enter(c, r)
+	c.msg(MSG_MESSAGE, "> taking your seat at your tournament table")
+	r.seat(c)
+}
+
+// resumeTournament reopens the tables of a tournament interrupted by a
+// shutdown.
+func (s *server) resumeTournament() {
+	for _, table := range s.tournaments.tables() {
+		if !s.tournaments.tableDone(table) {
+			s.openTable(table)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"landlord/config"
+	"landlord/server/util"
+)
+
+func nicks(n int) []string {
+	var players []string
+	for i := 0; i < n; i++ {
+		players = append(players, fmt.Sprintf("p%d", i))
+	}
+	return players
+}
+
+func TestSeatRound(t *testing.T) {
+	met := make(map[[2]string]int)
+	firstSeats := make(map[string]int)
+	for round := 0; round < 3; round++ {
+		tables, out := seatRound(nicks(9), round, 3, nil)
+		if len(tables) != 3 || len(out) != 0 {
+			t.Fatalf("round %d: expected 3 full tables, got %d and %d byes", round, len(tables), len(out))
+		}
+		seated := make(map[string]bool)
+		for _, table := range tables {
+			firstSeats[table[0]]++
+			for i, a := range table {
+				if seated[a] {
+					t.Errorf("round %d: %s is seated twice", round, a)
+				}
+				seated[a] = true
+				for _, b := range table[i+1:] {
+					met[[2]string{a, b}]++
+					met[[2]string{b, a}]++
+				}
+			}
+		}
+		if len(seated) != 9 {
+			t.Errorf("round %d: %d of 9 players seated", round, len(seated))
+		}
+	}
+	for pair, n := range met {
+		if n > 1 {
+			t.Errorf("%s and %s met %d times", pair[0], pair[1], n)
+		}
+	}
+	if len(firstSeats) != 9 {
+		t.Errorf("expected the first seat to rotate, only %d players had it", len(firstSeats))
+	}
+}
+
+func TestSeatRoundByes(t *testing.T) {
+	byes := make(map[string]int)
+	for round := 0; round < 5; round++ {
+		tables, out := seatRound(nicks(10), round, 3, byes)
+		if len(tables) != 3 || len(out) != 1 {
+			t.Fatalf("round %d: expected 3 tables and 1 bye, got %d and %d", round, len(tables), len(out))
+		}
+		if byes[out[0]] > 0 {
+			t.Errorf("round %d: %s sits out twice", round, out[0])
+		}
+		byes[out[0]]++
+	}
+}
+
+func TestTournament(t *testing.T) {
+	dir := t.TempDir()
+	ts, err := loadTournaments(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.open("league", 2, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.open("other", 2, 1); err == nil {
+		t.Error("expected a second tournament to be rejected")
+	}
+	for _, nick := range []string{"alice", "bob", "carol"} {
+		if err := ts.join(nick); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ts.join("Alice"); err == nil {
+		t.Error("expected a second registration to be rejected")
+	}
+	if err := ts.canStart(3); err != nil {
+		t.Fatal(err)
+	}
+
+	for number := 1; number <= 2; number++ {
+		round, _, err := ts.next(3)
+		if err != nil || round == nil || round.Number != number {
+			t.Fatalf("round %d: unexpected %v %v", number, round, err)
+		}
+		table := ts.table("bob")
+		if table == nil || ts.tableDone(table) {
+			t.Fatalf("round %d: expected bob to be seated", number)
+		}
+		ts.record(table, util.Result{Scores: map[string]int{"alice": 2, "bob": -1, "carol": -1}})
+		if !ts.tableDone(table) || !ts.roundDone() {
+			t.Errorf("round %d: expected the round to be done", number)
+		}
+	}
+
+	// the running tournament survives a restart
+	ts, err = loadTournaments(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	round, finished, err := ts.next(3)
+	if err != nil || round != nil || finished == nil {
+		t.Fatalf("expected the tournament to finish, got %v %v %v", round, finished, err)
+	}
+	if finished.Standings[0].Nick != "alice" || finished.Standings[0].Score != 4 || finished.Standings[0].Wins != 2 {
+		t.Errorf("unexpected standings %+v", finished.Standings)
+	}
+
+	// finished tournaments are archived
+	ts, _ = loadTournaments(dir)
+	archived, err := ts.find("league")
+	if err != nil || archived.State != TOURNAMENT_FINISHED {
+		t.Fatalf("expected the archived tournament, got %v", err)
+	}
+	if err := ts.open("league", 1, 1); err == nil {
+		t.Error("expected the name of an archived tournament to be rejected")
+	}
+}
+
+// TestTableWaitsForGame opens a tournament table for a player who is playing
+// in another room, who takes the seat only once that game is over.
+func TestTableWaitsForGame(t *testing.T) {
+	s, addr := testServer(t)
+	landlordDeck(t)(s)
+	alice, bob, carol := dialAccount(t, addr, "alice"), dialTest(t, addr, "bob"), dialTest(t, addr, "carol")
+	dave, erin := dialAccount(t, addr, "dave"), dialAccount(t, addr, "erin")
+	seatAll(alice, bob, carol)
+
+	s.mu.Lock()
+	if err := s.tournaments.open("cup", 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, nick := range []string{"alice", "dave", "erin"} {
+		if err := s.tournaments.join(nick); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.nextRound()
+	s.mu.Unlock()
+
+	alice.expect("> [tournament] your table is ready, you take your seat once your game ends")
+	dave.expect("> taking your seat at your tournament table")
+	erin.expect("> taking your seat at your tournament table")
+
+	win(alice, bob, carol)
+	if bob.saw("game ends") || carol.saw("game ends") {
+		t.Errorf("expected the game to go on:\n%s", bob.transcript())
+	}
+	alice.expect("> taking your seat at your tournament table")
+	for _, c := range []*testClient{alice, dave, erin} {
+		c.expect("the landlord takes the cards")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.metrics.gamesCompleted != 1 || s.metrics.disconnectsMidGame != 0 {
+		t.Errorf("expected a completed game, got %d completed and %d disconnects",
+			s.metrics.gamesCompleted, s.metrics.disconnectsMidGame)
+	}
+}
+
+// TestTableForfeit plays a table whose player doesn't show up for the first
+// deal and leaves during the second, which the player forfeits both times.
+func TestTableForfeit(t *testing.T) {
+	s, addr := testServer(t, func(cfg *config.Config) {
+		cfg.Rules.TurnTimeout = config.Duration(500 * time.Millisecond)
+	})
+	alice, erin := dialAccount(t, addr, "alice"), dialAccount(t, addr, "erin")
+
+	s.mu.Lock()
+	if err := s.tournaments.open("cup", 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	for _, nick := range []string{"alice", "dave", "erin"} {
+		if err := s.tournaments.join(nick); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.nextRound()
+	s.mu.Unlock()
+
+	for _, c := range []*testClient{alice, erin} {
+		c.expect("> taking your seat at your tournament table")
+		c.expect("> [tournament] dave forfeit the deal")
+	}
+	dave := dialAccount(t, addr, "dave")
+	dave.expect("> taking your seat at your tournament table")
+	for _, c := range []*testClient{alice, dave, erin} {
+		c.expect("the landlord takes the cards")
+	}
+	dave.send("/quit")
+	for _, c := range []*testClient{alice, erin} {
+		c.expect("> [tournament] dave forfeit the deal")
+		c.expect("> [tournament] cup is over")
+	}
+
+	archived, err := s.tournaments.find("cup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	standings := archived.standings()
+	if standings[2].Nick != "dave" || standings[2].Score != -4 || standings[0].Score != 2 || standings[0].Deals != 2 {
+		t.Errorf("unexpected standings %+v", standings)
+	}
+}
@@ -1,10 +1,6 @@
 package util
 
-import (
-	"fmt"
-
-	"golang.org/x/exp/slices"
-)
+import "golang.org/x/exp/slices"
 
 type Card struct {
 	Point cardPoint
@@ -12,69 +8,30 @@ type Card struct {
 }
 
 func (c Card) String() string {
-	var color string
-	var point string
-	switch c.Color {
-	case SPADE:
-		color = "♠"
-	case CLUBS:
-		color = "♣"
-	case HEART:
-		color = "♥"
-	case DIAMOND:
-		color = "♦"
-	case NONE:
-		color = ""
-	}
-	switch c.Point {
-	case THREE:
-		point = " 3"
-	case FOUR:
-		point = " 4"
-	case FIVE:
-		point = " 5"
-	case SIX:
-		point = " 6"
-	case SEVEN:
-		point = " 7"
-	case EIGHT:
-		point = " 8"
-	case NINE:
-		point = " 9"
-	case TEN:
-		point = " 10"
-	case JACK:
-		point = " J"
-	case QUEEN:
-		point = " Q"
-	case KING:
-		point = " K"
-	case ACE:
-		point = " A"
-	case TWO:
-		point = " 2"
-	case BLACK_JOKER:
-		point = "joker"
-	case RED_JOKER:
-		point = "JOKER"
-	}
-	return fmt.Sprintf("%v%v", color, point)
+	return suitSymbols[c.Color] + pointNames[c.Point]
 }
 
-func CardsToString(cards []*Card) string {
-	str := "["
-	for _, c := range cards {
-		str += c.String() + " "
-	}
-	if len(str) > 1 {
-		str = str[:len(str)-1]
+// ID identifies the card among the cards of the deck, it is the index of the
+// card in a new deck. A card without suit, which stands for any card of its
+// point, has the ID -1.
+func (c Card) ID() int {
+	switch {
+	case c.Point == BLACK_JOKER || c.Point == RED_JOKER:
+		return NUM_CARDS - 2 + int(c.Point-BLACK_JOKER)
+	case c.Color == NONE:
+		return -1
 	}
-	str += "]"
-	return str
+	return int(c.Color)*13 + int(c.Point)
 }
 
+// Equal reports whether c and c2 are the same card of the deck.
 func (c Card) Equal(c2 Card) bool {
-	return c.Point == c2.Point
+	return c.ID() >= 0 && c.ID() == c2.ID()
+}
+
+// Matches reports whether card is c, or of the point of c if c has no suit.
+func (c Card) Matches(card Card) bool {
+	return c.Point == card.Point && (c.Color == NONE || c.Color == card.Color)
 }
 
 type cardColor int
@@ -118,27 +75,33 @@ func Valid(cards []*Card) bool {
 }
 
 func Contains(cards []*Card, cardsInfo []*Card) bool {
-	if len(cardsInfo) == 0 {
-		return true
-	}
-	if len(cards) < len(cardsInfo) {
-		return false
-	}
-	visitedCardsIdx := make(map[int]struct{})
-	for _, cardInfo := range cardsInfo {
-		notFound := true
-		for i, c := range cards {
-			if _, ok := visitedCardsIdx[i]; !ok && cardInfo.Equal(*c) {
-				notFound = false
-				visitedCardsIdx[i] = struct{}{}
-				break
+	return match(cards, cardsInfo) != nil
+}
+
+// match returns the index of the card of cards matched by each of cardsInfo,
+// or nil if not all of them are found. The cards of a given suit are matched
+// first, so that the others don't take them.
+func match(cards []*Card, cardsInfo []*Card) []int {
+	matched := make([]int, len(cardsInfo))
+	taken := make(map[int]bool)
+	for _, anySuit := range []bool{false, true} {
+		for i, cardInfo := range cardsInfo {
+			if (cardInfo.Color == NONE) != anySuit {
+				continue
+			}
+			found := false
+			for j, c := range cards {
+				if !taken[j] && cardInfo.Matches(*c) {
+					matched[i], taken[j], found = j, true, true
+					break
+				}
+			}
+			if !found {
+				return nil
 			}
-		}
-		if notFound {
-			return false
 		}
 	}
-	return true
+	return matched
 }
 
 func Sort(cards []*Card) {
@@ -174,21 +137,27 @@ func Sort(cards []*Card) {
 			}
 		}
 	case len(cards) == 6 || len(cards) == 8 || len(cards) == 10:
-		for i := 0; i < len(cards)-2; i++ {
-			if cards[i].Point == cards[i+1].Point && cards[i].Point == cards[i+2].Point {
-				cards[0], cards[i] = cards[i], cards[0]
-				cards[1], cards[i+1] = cards[i+1], cards[1]
-				cards[2], cards[i+2] = cards[i+2], cards[2]
-				break
+		sortPlane(cards)
+	}
+}
+
+// sortPlane moves the two triples of a plane in front of the sorted cards,
+// which are left as they are if they aren't a plane.
+func sortPlane(cards []*Card) {
+	for point := THREE; point < ACE; point++ {
+		var triples, rest []*Card
+		var counts [2]int
+		for _, card := range cards {
+			if i := card.Point - point; (i == 0 || i == 1) && counts[i] < 3 {
+				counts[i]++
+				triples = append(triples, card)
+			} else {
+				rest = append(rest, card)
 			}
 		}
-		for i := 3; i < len(cards)-2; i++ {
-			if cards[i].Point == cards[i+1].Point && cards[i].Point == cards[i+2].Point {
-				cards[3], cards[i] = cards[i], cards[3]
-				cards[4], cards[i+1] = cards[i+1], cards[4]
-				cards[5], cards[i+2] = cards[i+2], cards[5]
-				break
-			}
+		if plane := append(triples, rest...); len(triples) == 6 && isPlane(plane) {
+			copy(cards, plane)
+			return
 		}
 	}
 }
@@ -207,46 +176,34 @@ func CompareTo(cards, lastCards []*Card) bool {
 
 	switch {
 	case isBomb(cards):
-		if isBomb(lastCards) && lastCards[0].Point > cards[0].Point {
+		// the rocket beats every bomb
+		if isBomb(lastCards) && lastCards[0].Point >= cards[0].Point {
 			return false
 		}
-	case len(cards) == len(lastCards):
-		switch {
-		case isSingle(cards), isTriple(cards):
-			if cards[0].Point <= lastCards[0].Point {
-				return false
-			}
-		case isDouble(cards):
-			if !isDouble(cards) || cards[0].Point <= lastCards[0].Point {
-				return false
-			}
-		case isTripleWithOne(cards):
-			if !isTripleWithOne(cards) || cards[0].Point <= lastCards[0].Point {
-				return false
-			}
-		case isTripleWithTwo(cards):
-			if !isTripleWithTwo(cards) || cards[0].Point <= lastCards[0].Point {
-				return false
-			}
-		case isStraight(cards):
-			if !isStraight(cards) || cards[0].Point <= lastCards[0].Point {
-				return false
-			}
-		case isDoubleStraight(cards):
-			if !isDoubleStraight(lastCards) || cards[0].Point <= lastCards[0].Point {
-				return false
-			}
-		case isPlane(cards):
-			if !isPlane(lastCards) || cards[0].Point <= lastCards[0].Point {
-				return false
-			}
-		}
-	default:
+	case isBomb(lastCards), len(cards) != len(lastCards), !sameKind(cards, lastCards):
+		return false
+	case cards[0].Point <= lastCards[0].Point:
 		return false
 	}
 	return true
 }
 
+// sameKind reports whether the sorted cards are combinations of the same
+// kind, which isn't a bomb.
+func sameKind(cards, lastCards []*Card) bool {
+	for _, is := range []func([]*Card) bool{isSingle, isDouble, isTriple, isTripleWithOne, isTripleWithTwo, isStraight, isDoubleStraight, isPlane} {
+		if is(cards) {
+			return is(lastCards)
+		}
+	}
+	return false
+}
+
+// IsBomb reports whether the sorted cards are a bomb or a rocket.
+func IsBomb(cards []*Card) bool {
+	return isBomb(cards)
+}
+
 func isBomb(cards []*Card) bool {
 	if len(cards) != 4 {
 		if len(cards) == 2 {
@@ -318,7 +275,8 @@ func isStraight(cards []*Card) bool {
 			return false
 		}
 	}
-	return true
+	// the 2s and the jokers aren't part of straights
+	return cards[len(cards)-1].Point < TWO
 }
 
 func isDoubleStraight(cards []*Card) bool {
@@ -328,22 +286,22 @@ func isDoubleStraight(cards []*Card) bool {
 	if len(cards)%2 != 0 {
 		return false
 	}
-	for i := 0; i < len(cards)-2; i += 2 {
+	for i := 0; i < len(cards); i += 2 {
 		if cards[i].Point != cards[i+1].Point {
 			return false
 		}
-		if cards[i].Point != cards[i+2].Point-1 {
+		if i+2 < len(cards) && cards[i].Point != cards[i+2].Point-1 {
 			return false
 		}
 	}
-	return true
+	return cards[len(cards)-1].Point < TWO
 }
 
 func isPlane(cards []*Card) bool {
 	if len(cards) != 6 && len(cards) != 8 && len(cards) != 10 {
 		return false
 	}
-	if cards[0].Point != cards[3].Point-1 {
+	if cards[0].Point != cards[3].Point-1 || cards[3].Point >= TWO {
 		return false
 	}
 
@@ -1,8 +1,11 @@
 package util
 
 import (
+	"math/rand"
 	"strings"
 	"testing"
+
+	"golang.org/x/exp/slices"
 )
 
 func TestCard(t *testing.T) {
@@ -21,3 +24,208 @@ func TestSplit(t *testing.T) {
 	splittedStr := strings.Split(str, ", ")
 	t.Log(splittedStr)
 }
+
+func TestCardID(t *testing.T) {
+	deck := NewDeck()
+	for i, card := range deck.Cards {
+		if card.ID() != i {
+			t.Errorf("expected %v to have the ID %d, got %d", card, i, card.ID())
+		}
+	}
+	if id := (Card{THREE, NONE}).ID(); id != -1 {
+		t.Errorf("expected a card without suit to have no ID, got %d", id)
+	}
+	if !(Card{ACE, HEART}).Equal(Card{ACE, HEART}) || (Card{ACE, HEART}).Equal(Card{ACE, SPADE}) || (Card{ACE, NONE}).Equal(Card{ACE, NONE}) {
+		t.Error("expected only the same cards of the deck to be equal")
+	}
+}
+
+// kinds are the combinations in the order the rules check them, bombs
+// first.
+var kinds = []struct {
+	name string
+	is   func([]*Card) bool
+}{
+	{"bomb", isBomb}, {"plane", isPlane}, {"double straight", isDoubleStraight},
+	{"straight", isStraight}, {"triple with two", isTripleWithTwo},
+	{"triple with one", isTripleWithOne}, {"triple", isTriple},
+	{"double", isDouble}, {"single", isSingle},
+}
+
+func kindOf(cards []*Card) string {
+	for _, kind := range kinds {
+		if kind.is(cards) {
+			return kind.name
+		}
+	}
+	return "invalid"
+}
+
+func sorted(cards []*Card) []*Card {
+	cards = append([]*Card(nil), cards...)
+	Sort(cards)
+	return cards
+}
+
+// dealBytes deals a hand of up to 20 cards for every byte string from one
+// deck. Every byte is the point of a card, which is skipped if no card of the
+// point is left.
+func dealBytes(data ...[]byte) [][]*Card {
+	deck := NewDeck()
+	left := deck.Cards[:]
+	var hands [][]*Card
+	for _, bytes := range data {
+		cards := []*Card{}
+		for _, b := range bytes {
+			if len(cards) == 20 {
+				break
+			}
+			point := cardPoint(b % byte(RED_JOKER+1))
+			if i := slices.IndexFunc(left, func(c Card) bool { return c.Point == point }); i >= 0 {
+				card := left[i]
+				cards = append(cards, &card)
+				left = append(left[:i:i], left[i+1:]...)
+			}
+		}
+		hands = append(hands, cards)
+	}
+	return hands
+}
+
+// checkHand checks that the kind of the cards doesn't depend on their order.
+func checkHand(t *testing.T, cards []*Card) {
+	t.Helper()
+	Valid(cards)
+	CompareTo(cards, cards)
+	hand := sorted(cards)
+	reversed := append([]*Card(nil), cards...)
+	slices.Reverse(reversed)
+	rotated := append(append([]*Card(nil), cards[len(cards)/2:]...), cards[:len(cards)/2]...)
+	for _, other := range [][]*Card{sorted(reversed), sorted(rotated)} {
+		if kindOf(other) != kindOf(hand) || Valid(other) != Valid(hand) {
+			t.Fatalf("%v is a %s, but %v is a %s", hand, kindOf(hand), other, kindOf(other))
+		}
+		if len(hand) > 0 && Valid(hand) && other[0].Point != hand[0].Point {
+			t.Fatalf("%v and %v are sorted differently", hand, other)
+		}
+	}
+	if Valid(hand) != (kindOf(hand) != "invalid" || len(hand) == 0) {
+		t.Fatalf("%v is a %s, but valid is %v", hand, kindOf(hand), Valid(hand))
+	}
+	if len(hand) > 0 && CompareTo(hand, hand) {
+		t.Fatalf("%v beats itself", hand)
+	}
+}
+
+// checkCompare checks the invariants of CompareTo for two hands dealt from
+// one deck.
+func checkCompare(t *testing.T, cards, lastCards []*Card) {
+	t.Helper()
+	cards, lastCards = sorted(cards), sorted(lastCards)
+	if len(cards) == 0 || len(lastCards) == 0 || !Valid(cards) || !Valid(lastCards) {
+		return
+	}
+	beats, beaten := CompareTo(cards, lastCards), CompareTo(lastCards, cards)
+	switch {
+	case beats && beaten:
+		t.Fatalf("%v and %v beat each other", cards, lastCards)
+	case len(cards) == 2 && isBomb(cards) && !beats:
+		t.Fatalf("the rocket doesn't beat %v", lastCards)
+	case isBomb(cards) && !isBomb(lastCards) && !beats:
+		t.Fatalf("the bomb %v doesn't beat %v", cards, lastCards)
+	case !isBomb(cards) && !isBomb(lastCards) && len(cards) == len(lastCards) &&
+		kindOf(cards) == kindOf(lastCards) && cards[0].Point != lastCards[0].Point && beats == beaten:
+		t.Fatalf("either %v or %v should win", cards, lastCards)
+	case beats && !isBomb(cards) && kindOf(cards) != kindOf(lastCards):
+		t.Fatalf("the %s %v beats the %s %v", kindOf(cards), cards, kindOf(lastCards), lastCards)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		cards []*Card
+		kind  string
+	}{
+		{hand(THREE, THREE, FOUR, FOUR, FIVE, SIX), "invalid"},
+		{hand(THREE, THREE, FOUR, FOUR, FIVE, FIVE), "double straight"},
+		{hand(JACK, QUEEN, KING, ACE, TWO), "invalid"},
+		{hand(TEN, JACK, QUEEN, KING, ACE), "straight"},
+		{hand(ACE, ACE, ACE, TWO, TWO, TWO), "invalid"},
+		{hand(THREE, THREE, THREE, THREE, FIVE, FIVE, FIVE, SIX, SIX, SIX), "plane"},
+		{hand(SEVEN, FIVE, FIVE, FIVE, SIX, SIX, SIX, SEVEN, SEVEN, SEVEN), "plane"},
+		{hand(FOUR, THREE, FOUR, FOUR), "triple with one"},
+		{hand(RED_JOKER, BLACK_JOKER), "bomb"},
+	}
+	for _, test := range tests {
+		if kind := kindOf(sorted(test.cards)); kind != test.kind {
+			t.Errorf("expected %v to be %q, got %q", test.cards, test.kind, kind)
+		}
+		checkHand(t, test.cards)
+	}
+}
+
+func TestCompareTo(t *testing.T) {
+	tests := []struct {
+		cards, lastCards []*Card
+		beats            bool
+	}{
+		{hand(SIX, SIX, SIX, FOUR), hand(FIVE, FIVE, FIVE, FIVE), false},
+		{hand(FOUR, FIVE, SIX, SEVEN, EIGHT, NINE), hand(THREE, THREE, FOUR, FOUR, FIVE, FIVE), false},
+		{hand(FOUR, FOUR, FOUR, THREE, THREE), hand(THREE, FOUR, FIVE, SIX, SEVEN), false},
+		{hand(FIVE, FIVE, FIVE, FIVE), hand(FIVE, FIVE, FIVE, FIVE), false},
+		{hand(SIX, SIX, SIX, SIX), hand(TWO), true},
+		{hand(BLACK_JOKER, RED_JOKER), hand(TWO, TWO, TWO, TWO), true},
+		{hand(FIVE, FIVE, FIVE, SIX, SIX, SIX, THREE, THREE, THREE, THREE), hand(FOUR, FOUR, FOUR, FIVE, FIVE, FIVE, SEVEN, SEVEN, NINE, NINE), true},
+	}
+	for _, test := range tests {
+		if beats := CompareTo(sorted(test.cards), sorted(test.lastCards)); beats != test.beats {
+			t.Errorf("expected %v beating %v to be %v", test.cards, test.lastCards, test.beats)
+		}
+	}
+}
+
+// TestCardProperties checks random hands of a few neighbouring points, which
+// are likely to be valid combinations.
+func TestCardProperties(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := func() []byte {
+		low := r.Intn(int(RED_JOKER))
+		bytes := make([]byte, 1+r.Intn(10))
+		for i := range bytes {
+			bytes[i] = byte(low + r.Intn(6))
+		}
+		return bytes
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < 20000; i++ {
+		hands := dealBytes(random(), random())
+		checkHand(t, hands[0])
+		checkCompare(t, hands[0], hands[1])
+		seen[kindOf(sorted(hands[0]))] = true
+	}
+	for _, kind := range kinds {
+		if !seen[kind.name] {
+			t.Errorf("no %s was dealt", kind.name)
+		}
+	}
+}
+
+func FuzzHand(f *testing.F) {
+	for _, seed := range [][]byte{{0}, {0, 1, 2, 3, 4}, {0, 0, 0, 1, 1, 1, 5, 5, 7, 7}, {13, 14}, {0, 0, 0, 0}} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, bytes []byte) {
+		checkHand(t, dealBytes(bytes)[0])
+	})
+}
+
+func FuzzCompareTo(f *testing.F) {
+	f.Add([]byte{3, 3, 3, 1}, []byte{2, 2, 2, 2})
+	f.Add([]byte{1, 2, 3, 4, 5, 6}, []byte{0, 0, 1, 1, 2, 2})
+	f.Add([]byte{13, 14}, []byte{12, 12, 12, 12})
+	f.Fuzz(func(t *testing.T, cards, lastCards []byte) {
+		hands := dealBytes(cards, lastCards)
+		checkHand(t, hands[0])
+		checkCompare(t, hands[0], hands[1])
+	})
+}
@@ -3,12 +3,31 @@ package util
 import (
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 )
 
 const NUM_CARDS = 54
 
-var R = rand.New(rand.NewSource(time.Now().UnixNano()))
+// R shuffles the decks, it's safe for concurrent use
+var R = rand.New(&lockedSource{src: rand.NewSource(time.Now().UnixNano())})
+
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
 
 type Deck struct {
 	Cards   [NUM_CARDS]Card
@@ -0,0 +1,266 @@
+package util
+
+import (
+	"errors"
+	"time"
+)
+
+// MAX_BID is the highest bid, which makes the bidder the landlord at once
+const MAX_BID = 3
+
+var (
+	ErrNotPlaying = errors.New("> there is no game in progress")
+	ErrNotTurn    = errors.New("> it's not your turn")
+	ErrBidding    = errors.New("> the landlord is still being chosen, type /bid <points> or /bid 0 to pass")
+	ErrNotBidding = errors.New("> the landlord has been chosen already")
+	ErrInvalidBid = errors.New("> the bid must be higher than the current bid, at most 3, or 0 to pass")
+	ErrMustPlay   = errors.New("> you must play, you lead the trick")
+)
+
+// The game engine is driven by the actions of the players, which it checks
+// against the rules before applying them to the game. Every action results
+// in events describing what happened, which the server turns into messages.
+// The engine knows nothing about connections, so games can be played
+// without a network.
+
+type Action interface {
+	seat() int
+}
+
+// Bid bids points for becoming the landlord, zero passes.
+type Bid struct {
+	Seat   int
+	Points int
+}
+
+type Play struct {
+	Seat  int
+	Cards []*Card
+}
+
+type Pass struct {
+	Seat int
+}
+
+// Leave ends the game because the player left.
+type Leave struct {
+	Seat int
+}
+
+func (a Bid) seat() int   { return a.Seat }
+func (a Play) seat() int  { return a.Seat }
+func (a Pass) seat() int  { return a.Seat }
+func (a Leave) seat() int { return a.Seat }
+
+type Event interface{}
+
+// TurnStarted asks the player at Seat to act, either to bid or to beat the
+// Last cards played by the player at LastSeat. Nothing has to be beaten if
+// Last is empty.
+type TurnStarted struct {
+	Seat     int
+	Bidding  bool
+	Bid      int
+	Last     []*Card
+	LastSeat int
+}
+
+type BidMade struct {
+	Seat   int
+	Points int
+}
+
+// LandlordChosen tells who became the landlord for Bid points and took the
+// remaining Cards of the deck.
+type LandlordChosen struct {
+	Seat  int
+	Bid   int
+	Cards []*Card
+}
+
+type CardsPlayed struct {
+	Seat      int
+	Cards     []*Card
+	Remaining int
+	Bomb      bool
+}
+
+type Passed struct {
+	Seat int
+}
+
+// TrickWon tells that everybody passed on the cards of the player at Seat,
+// who leads the next trick.
+type TrickWon struct {
+	Seat int
+}
+
+// GameEnded tells that the player at Winner played all cards. If the game
+// was aborted, Winner is -1 and Left is the seat of the player who left, if
+// any.
+type GameEnded struct {
+	Winner int
+	Left   int
+	Result Result
+}
+
+func (e GameEnded) Aborted() bool {
+	return e.Winner < 0
+}
+
+// Deal starts a game of players, who take their seats in that order. The
+// player at the first seat becomes the landlord, unless bidding is set, in
+// which case that player bids first.
+func (g *Game) Deal(players []*Player, first int, bidding bool) ([]Event, error) {
+	g.TakeSeats(players)
+	for _, player := range players {
+		player.Position = FARMER
+		if err := player.Deal(&g.Deck, 17); err != nil {
+			return nil, err
+		}
+	}
+	g.State = STATE_PLAYING
+	g.StartedAt = time.Now()
+	g.Landlord = nil
+	g.LastPlayer = nil
+	g.LastUsedCards = nil
+	g.Played = nil
+	g.Bid = 0
+	g.bids = 0
+	g.bidder = nil
+	g.CurrentPlayer = players[first]
+	if !bidding {
+		return g.chooseLandlord(g.CurrentPlayer)
+	}
+	g.Bidding = true
+	g.first = g.CurrentPlayer
+	return []Event{g.Turn()}, nil
+}
+
+// Turn is the TurnStarted event of the current player.
+func (g *Game) Turn() Event {
+	turn := TurnStarted{Seat: g.CurrentPlayer.Seat, Bidding: g.Bidding, Bid: g.Bid, LastSeat: -1}
+	if !g.Bidding && len(g.LastUsedCards) > 0 {
+		turn.Last = g.LastUsedCards
+		turn.LastSeat = g.LastPlayer.Seat
+	}
+	return turn
+}
+
+// Apply checks action against the rules and applies it to the game. It
+// returns the resulting events, or an error if the action isn't allowed,
+// in which case the game doesn't change.
+func (g *Game) Apply(action Action) ([]Event, error) {
+	if g.State != STATE_PLAYING || len(g.Order) == 0 {
+		return nil, ErrNotPlaying
+	}
+	if action.seat() < 0 || action.seat() >= len(g.Order) {
+		return nil, ErrNotTurn
+	}
+	player := g.Order[action.seat()]
+	if leave, ok := action.(Leave); ok {
+		return g.Abort(leave.Seat), nil
+	}
+	if player != g.CurrentPlayer {
+		return nil, ErrNotTurn
+	}
+
+	switch action := action.(type) {
+	case Bid:
+		if !g.Bidding {
+			return nil, ErrNotBidding
+		}
+		return g.bid(player, action.Points)
+	case Play:
+		if g.Bidding {
+			return nil, ErrBidding
+		}
+		return g.play(player, action.Cards)
+	case Pass:
+		if g.Bidding {
+			return nil, ErrBidding
+		}
+		if len(g.LastUsedCards) == 0 {
+			return nil, ErrMustPlay
+		}
+		return append([]Event{Passed{player.Seat}}, g.next()...), nil
+	}
+	return nil, errors.New("> unknown action")
+}
+
+// Abort ends the game without a winner, because the player at seat left or
+// because it was ended by an admin if seat is -1.
+func (g *Game) Abort(seat int) []Event {
+	if g.State != STATE_PLAYING {
+		return nil
+	}
+	g.State = STATE_OVER
+	g.Bidding = false
+	return []Event{GameEnded{Winner: -1, Left: seat}}
+}
+
+func (g *Game) bid(player *Player, points int) ([]Event, error) {
+	if points != 0 && (points <= g.Bid || points > MAX_BID) {
+		return nil, ErrInvalidBid
+	}
+	events := []Event{BidMade{player.Seat, points}}
+	g.bids++
+	if points > 0 {
+		g.Bid = points
+		g.bidder = player
+	}
+	switch {
+	case g.Bid == MAX_BID || (g.bids == len(g.Order) && g.bidder != nil):
+		landlord, err := g.chooseLandlord(g.bidder)
+		return append(events, landlord...), err
+	case g.bids == len(g.Order):
+		// nobody bid, the first bidder has to be the landlord
+		g.Bid = 1
+		landlord, err := g.chooseLandlord(g.first)
+		return append(events, landlord...), err
+	}
+	g.CurrentPlayer = g.Next(player)
+	return append(events, g.Turn()), nil
+}
+
+func (g *Game) chooseLandlord(player *Player) ([]Event, error) {
+	cards, err := g.Deck.Deal(3)
+	if err != nil {
+		return nil, err
+	}
+	player.Cards = append(player.Cards, cards...)
+	player.Sort()
+	player.Position = LANDLORD
+	g.Landlord = player
+	g.Bidding = false
+	g.CurrentPlayer = player
+	return []Event{LandlordChosen{player.Seat, g.Bid, cards}, g.Turn()}, nil
+}
+
+func (g *Game) play(player *Player, cards []*Card) ([]Event, error) {
+	if err := player.Use(cards, g.LastUsedCards); err != nil {
+		return nil, err
+	}
+	g.Plays++
+	g.Played = append(g.Played, cards...)
+	g.LastUsedCards = cards
+	g.LastPlayer = player
+	events := []Event{CardsPlayed{player.Seat, cards, len(player.Cards), IsBomb(cards)}}
+	if len(player.Cards) == 0 {
+		g.State = STATE_OVER
+		return append(events, GameEnded{Winner: player.Seat, Left: -1, Result: g.Result(player)}), nil
+	}
+	return append(events, g.next()...), nil
+}
+
+// next passes the turn on. The trick is won once the turn comes back to the
+// player who played last.
+func (g *Game) next() []Event {
+	var events []Event
+	g.CurrentPlayer = g.Next(g.CurrentPlayer)
+	if g.CurrentPlayer == g.LastPlayer {
+		g.LastUsedCards = nil
+		events = append(events, TrickWon{g.CurrentPlayer.Seat})
+	}
+	return append(events, g.Turn())
+}
@@ -0,0 +1,256 @@
+package util
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+)
+
+func newEngineGame(t *testing.T, bidding bool) (*Game, []*Player, []Event) {
+	t.Helper()
+	g := NewGame()
+	players := []*Player{NewPlayer("a", "a"), NewPlayer("b", "b"), NewPlayer("c", "c")}
+	events, err := g.Deal(players, 0, bidding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g, players, events
+}
+
+func hand(points ...cardPoint) []*Card {
+	var cards []*Card
+	for _, point := range points {
+		cards = append(cards, &Card{point, SPADE})
+	}
+	return cards
+}
+
+func expectEvents(t *testing.T, events []Event, err error, expected ...Event) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %#v", len(expected), events)
+	}
+	for i, event := range events {
+		switch e := expected[i].(type) {
+		case TurnStarted:
+			if turn, ok := event.(TurnStarted); !ok || turn.Seat != e.Seat || turn.Bidding != e.Bidding || len(turn.Last) != len(e.Last) {
+				t.Errorf("expected %#v, got %#v", e, event)
+			}
+		case CardsPlayed:
+			if played, ok := event.(CardsPlayed); !ok || played.Seat != e.Seat || played.Remaining != e.Remaining {
+				t.Errorf("expected %#v, got %#v", e, event)
+			}
+		case LandlordChosen:
+			if chosen, ok := event.(LandlordChosen); !ok || chosen.Seat != e.Seat || chosen.Bid != e.Bid || len(chosen.Cards) != 3 {
+				t.Errorf("expected %#v, got %#v", e, event)
+			}
+		case GameEnded:
+			if ended, ok := event.(GameEnded); !ok || ended.Winner != e.Winner || ended.Left != e.Left {
+				t.Errorf("expected %#v, got %#v", e, event)
+			}
+		default:
+			if event != expected[i] {
+				t.Errorf("expected %#v, got %#v", e, event)
+			}
+		}
+	}
+}
+
+func TestEnginePlay(t *testing.T) {
+	g, players, events := newEngineGame(t, false)
+	expectEvents(t, events, nil, LandlordChosen{Seat: 0}, TurnStarted{Seat: 0})
+	if len(players[0].Cards) != 20 || len(players[1].Cards) != 17 || players[0].Position != LANDLORD {
+		t.Fatal("expected the landlord to take the remaining cards")
+	}
+	if err := g.CheckCards(); err != nil {
+		t.Fatal(err)
+	}
+	players[0].Cards = hand(KING, THREE, THREE)
+	players[1].Cards = hand(ACE, FOUR)
+	players[2].Cards = hand(FIVE, SIX)
+
+	if _, err := g.Apply(Play{1, hand(FOUR)}); err != ErrNotTurn {
+		t.Errorf("expected a play out of turn to be rejected, got %v", err)
+	}
+	if _, err := g.Apply(Play{0, hand(ACE)}); err != ErrNotOwned {
+		t.Errorf("expected cards not owned to be rejected, got %v", err)
+	}
+	if _, err := g.Apply(Bid{0, 1}); err != ErrNotBidding {
+		t.Errorf("expected a bid after the landlord was chosen to be rejected, got %v", err)
+	}
+
+	events, err := g.Apply(Play{0, hand(THREE, THREE)})
+	expectEvents(t, events, err, CardsPlayed{Seat: 0, Remaining: 1}, TurnStarted{Seat: 1, Last: hand(THREE, THREE)})
+	if _, err := g.Apply(Play{1, hand(FOUR)}); err != ErrCannotBeat {
+		t.Errorf("expected a single not to beat a pair, got %v", err)
+	}
+	events, err = g.Apply(Pass{1})
+	expectEvents(t, events, err, Passed{1}, TurnStarted{Seat: 2, Last: hand(THREE, THREE)})
+	events, err = g.Apply(Pass{2})
+	expectEvents(t, events, err, Passed{2}, TrickWon{0}, TurnStarted{Seat: 0})
+	if _, err := g.Apply(Pass{0}); err != ErrMustPlay {
+		t.Errorf("expected the player who leads not to pass, got %v", err)
+	}
+
+	events, err = g.Apply(Play{0, hand(KING)})
+	expectEvents(t, events, err, CardsPlayed{Seat: 0, Remaining: 0}, GameEnded{Winner: 0, Left: -1})
+	ended := events[1].(GameEnded)
+	// the farmers never played, which makes it a spring
+	if !ended.Result.LandlordWon || !ended.Result.Spring || ended.Result.Scores["a"] != 4 || ended.Result.Scores["b"] != -2 {
+		t.Errorf("unexpected result %+v", ended.Result)
+	}
+	if g.State != STATE_OVER {
+		t.Error("expected the game to be over")
+	}
+	if _, err := g.Apply(Pass{1}); err != ErrNotPlaying {
+		t.Errorf("expected actions after the game to be rejected, got %v", err)
+	}
+}
+
+func TestEngineBidding(t *testing.T) {
+	g, players, events := newEngineGame(t, true)
+	expectEvents(t, events, nil, TurnStarted{Seat: 0, Bidding: true})
+	if _, err := g.Apply(Pass{0}); err != ErrBidding {
+		t.Errorf("expected passing the turn to be rejected while bidding, got %v", err)
+	}
+
+	events, err := g.Apply(Bid{0, 1})
+	expectEvents(t, events, err, BidMade{0, 1}, TurnStarted{Seat: 1, Bidding: true})
+	if _, err := g.Apply(Bid{1, 1}); err != ErrInvalidBid {
+		t.Errorf("expected a bid not higher than the last to be rejected, got %v", err)
+	}
+	events, err = g.Apply(Bid{1, 0})
+	expectEvents(t, events, err, BidMade{1, 0}, TurnStarted{Seat: 2, Bidding: true})
+	events, err = g.Apply(Bid{2, 2})
+	expectEvents(t, events, err, BidMade{2, 2}, LandlordChosen{Seat: 2, Bid: 2}, TurnStarted{Seat: 2})
+	if g.Landlord != players[2] || len(players[2].Cards) != 20 {
+		t.Error("expected the highest bidder to become the landlord")
+	}
+	if err := g.CheckCards(); err != nil {
+		t.Fatal(err)
+	}
+
+	players[2].Cards = hand(ACE)
+	events, _ = g.Apply(Play{2, hand(ACE)})
+	if result := events[1].(GameEnded).Result; result.Multiplier != 4 || result.Scores["c"] != 8 {
+		t.Errorf("expected the bid to multiply the scores, got %+v", result)
+	}
+
+	g, _, _ = newEngineGame(t, true)
+	g.Apply(Bid{0, 0})
+	events, err = g.Apply(Bid{1, 3})
+	expectEvents(t, events, err, BidMade{1, 3}, LandlordChosen{Seat: 1, Bid: 3}, TurnStarted{Seat: 1})
+
+	g, _, _ = newEngineGame(t, true)
+	g.Apply(Bid{0, 0})
+	g.Apply(Bid{1, 0})
+	events, err = g.Apply(Bid{2, 0})
+	expectEvents(t, events, err, BidMade{2, 0}, LandlordChosen{Seat: 0, Bid: 1}, TurnStarted{Seat: 0})
+}
+
+func TestEngineLeave(t *testing.T) {
+	g, _, _ := newEngineGame(t, true)
+	events, err := g.Apply(Leave{2})
+	expectEvents(t, events, err, GameEnded{Winner: -1, Left: 2})
+	if !events[0].(GameEnded).Aborted() || g.State != STATE_OVER {
+		t.Error("expected the game to be aborted")
+	}
+	if events := g.Abort(-1); events != nil {
+		t.Error("expected an ended game not to be aborted again")
+	}
+}
+
+func TestCheckCards(t *testing.T) {
+	g, players, _ := newEngineGame(t, true)
+	if err := g.CheckCards(); err != nil {
+		t.Fatal(err)
+	}
+	card := players[1].Cards[0]
+	players[0].Cards = append(players[0].Cards, card)
+	if err := g.CheckCards(); err == nil {
+		t.Errorf("expected %v to be found twice", card)
+	}
+	players[1].Cards = players[1].Cards[1:]
+	if err := g.CheckCards(); err != nil {
+		t.Errorf("expected %v to be in one place: %v", card, err)
+	}
+	players[0].Cards = players[0].Cards[:len(players[0].Cards)-1]
+	if err := g.CheckCards(); err == nil {
+		t.Errorf("expected %v to be missing", card)
+	}
+}
+
+// TestEngineCards plays random games, in which the players follow the
+// recommendations or pass, and checks that every card stays in one place.
+// The cards are played by suit or by point only, and the games are saved and
+// restored on the way.
+func TestEngineCards(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		g, _, _ := newEngineGame(t, i%2 == 0)
+		for step := 0; g.State == STATE_PLAYING; step++ {
+			if step > 1000 {
+				t.Fatal("expected the game to end")
+			}
+			player := g.CurrentPlayer
+			var action Action = Pass{player.Seat}
+			switch recommend := player.Recommend(g.LastUsedCards); {
+			case g.Bidding:
+				action = Bid{player.Seat, r.Intn(MAX_BID + 1)}
+				if points := action.(Bid).Points; points != 0 && points <= g.Bid {
+					action = Bid{player.Seat, 0}
+				}
+			case len(recommend) > 0 && (len(g.LastUsedCards) == 0 || r.Intn(5) > 0):
+				anySuit := r.Intn(2) == 0
+				var cards []*Card
+				for _, c := range recommend {
+					card := *c
+					if anySuit {
+						card.Color = NONE
+					}
+					cards = append(cards, &card)
+				}
+				action = Play{player.Seat, cards}
+			}
+
+			events, err := g.Apply(action)
+			if err != nil {
+				t.Fatalf("%+v on %v: %v", action, g.LastUsedCards, err)
+			}
+			if err := g.CheckCards(); err != nil {
+				t.Fatalf("after %#v: %v", action, err)
+			}
+			if play, ok := action.(Play); ok {
+				for j, c := range events[0].(CardsPlayed).Cards {
+					if !play.Cards[j].Matches(*c) || c.ID() < 0 {
+						t.Fatalf("%v was played for %v", c, play.Cards[j])
+					}
+				}
+			}
+
+			if g.State == STATE_PLAYING && !g.Bidding && step%10 == 0 {
+				byts, err := json.Marshal(g.Snapshot())
+				if err != nil {
+					t.Fatal(err)
+				}
+				var snap Snapshot
+				if err := json.Unmarshal(byts, &snap); err != nil {
+					t.Fatal(err)
+				}
+				restored := NewGame()
+				for _, player := range g.Order {
+					restored.AddPlayer(player.ID, player.Nick)
+				}
+				if _, err := restored.Restore(&snap); err != nil {
+					t.Fatal(err)
+				}
+				if err := restored.CheckCards(); err != nil {
+					t.Fatalf("after restoring the game: %v", err)
+				}
+			}
+		}
+	}
+}
@@ -1,12 +1,16 @@
 package util
 
 import (
-	"net"
+	"errors"
+	"fmt"
 	"sync"
+	"time"
 )
 
 const NUM_PLAYERS = 3
 
+var ErrTableFull = errors.New("> all seats are taken")
+
 type GameState int
 
 const (
@@ -16,50 +20,82 @@ const (
 )
 
 type Game struct {
-	// Players          map[net.Addr]*Player
-	Players          sync.Map
-	NumPlayers       int
-	PlayerNum        int
-	Deck             Deck
-	State            GameState
-	CurrentUsedCards chan []*Card
-	LastUsedCards    []*Card
-	LastPlayer       *Player
-	CurrentPlayer    *Player
-	Landlord         *Player
+	// Players          map[PlayerID]*Player
+	Players       sync.Map
+	NumPlayers    int
+	PlayerNum     int
+	Deck          Deck
+	State         GameState
+	LastUsedCards []*Card
+	LastPlayer    *Player
+	CurrentPlayer *Player
+	Landlord      *Player
+	// Order are the players of the game in progress by seat, which is the
+	// order of the turns
+	Order     []*Player
+	StartedAt time.Time
+	Plays     int
+	// Played are the cards played in the game so far
+	Played []*Card
+
+	// Bidding is set while the players bid for becoming the landlord, Bid
+	// is the highest bid so far
+	Bidding bool
+	Bid     int
+	bids    int
+	bidder  *Player
+	first   *Player
 }
 
 func NewGame() *Game {
 	deck := NewDeck()
 	deck.Shuffle()
 	return &Game{
-		Players:          sync.Map{},
-		NumPlayers:       NUM_PLAYERS,
-		PlayerNum:        0,
-		Deck:             deck,
-		State:            STATE_WAITING,
-		CurrentUsedCards: make(chan []*Card, 1),
+		Players:    sync.Map{},
+		NumPlayers: NUM_PLAYERS,
+		PlayerNum:  0,
+		Deck:       deck,
+		State:      STATE_WAITING,
 	}
 }
 
-func (g *Game) AddPlayer(conn net.Conn, nick string) {
-	g.Players.Store(conn.RemoteAddr(), NewPlayer(conn, nick))
+// AddPlayer seats the player id at the lowest free seat. A player who is
+// seated already keeps the seat.
+func (g *Game) AddPlayer(id PlayerID, nick string) (*Player, error) {
+	if player, ok := g.Player(id); ok {
+		return player, nil
+	}
+	seat := g.freeSeat()
+	if seat < 0 {
+		return nil, ErrTableFull
+	}
+	player := NewPlayer(id, nick)
+	player.Seat = seat
+	g.Players.Store(id, player)
 	g.PlayerNum++
+	return player, nil
 }
 
-func (g *Game) RemovePlayer(conn net.Conn) bool {
-	if _, ok := g.Players.LoadAndDelete(conn.RemoteAddr()); ok {
+func (g *Game) RemovePlayer(id PlayerID) bool {
+	if _, ok := g.Players.LoadAndDelete(id); ok {
 		g.PlayerNum--
 		return true
 	}
 	return false
 }
 
-func (g *Game) ContainsPlayer(addr net.Addr) bool {
-	_, ok := g.Players.Load(addr)
-	return ok
+func (g *Game) Player(id PlayerID) (*Player, bool) {
+	player, ok := g.Players.Load(id)
+	if !ok {
+		return nil, false
+	}
+	return player.(*Player), true
 }
 
+func (g *Game) ContainsPlayer(id PlayerID) bool {
+	_, ok := g.Players.Load(id)
+	return ok
+}
 
 func (g *Game) NextState() {
 	switch g.State {
@@ -97,3 +133,44 @@ func (g *Game) NumReady() int {
 	})
 	return count
 }
+
+// CheckCards checks that every card of the deck is in exactly one place: in
+// the deck, which holds the bottom cards until the landlord takes them, in
+// the hand of a player or among the cards played.
+func (g *Game) CheckCards() error {
+	var places [NUM_CARDS]string
+	check := func(place string, cards []*Card) error {
+		for _, c := range cards {
+			id := c.ID()
+			if id < 0 || id >= NUM_CARDS {
+				return fmt.Errorf("%v in %s isn't a card of the deck", c, place)
+			}
+			if places[id] != "" {
+				return fmt.Errorf("%v is both in %s and in %s", c, places[id], place)
+			}
+			places[id] = place
+		}
+		return nil
+	}
+	var deck []*Card
+	for i := 0; i < g.Deck.Size(); i++ {
+		deck = append(deck, &g.Deck.Cards[i])
+	}
+	if err := check("the deck", deck); err != nil {
+		return err
+	}
+	for _, player := range g.Order {
+		if err := check("the hand of "+player.Nick, player.Cards); err != nil {
+			return err
+		}
+	}
+	if err := check("the cards played", g.Played); err != nil {
+		return err
+	}
+	for id, place := range places {
+		if place == "" {
+			return fmt.Errorf("%v is missing", NewDeck().Cards[id])
+		}
+	}
+	return nil
+}
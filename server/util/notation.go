@@ -0,0 +1,120 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// The notation of the cards is the point, 3 to 10, J, Q, K, A or 2, after
+// the symbol of the suit, as in ♠3 or ♥10, and joker and JOKER for the
+// black and the red joker. It is what the players see and what they type,
+// and the input may also be compact or use letters for the suits and the
+// jokers, see ParseCards.
+
+// pointNames are indexed by cardPoint
+var pointNames = []string{"3", "4", "5", "6", "7", "8", "9", "10", "J", "Q", "K", "A", "2", "joker", "JOKER"}
+
+// suitSymbols are indexed by cardColor
+var suitSymbols = []string{"♠", "♣", "♥", "♦", ""}
+
+// suitLetters are indexed by cardColor
+var suitLetters = []string{"s", "c", "h", "d"}
+
+// ParseCards reads cards in the notation. The letters may be in either case
+// and T stands for 10, the suit may also be given by its first letter, s, c,
+// h or d, and the jokers by bj and rj. The cards may be separated by spaces
+// or not, as in "33344", "TJQKA", "s3 h3" or "♠3♥3". A card without suit has
+// the color NONE and stands for a card of any suit.
+func ParseCards(text string) ([]Card, error) {
+	var cards []Card
+	rest := strings.TrimSpace(text)
+	for rest != "" {
+		card, n := parseCard(rest)
+		if n == 0 {
+			return nil, fmt.Errorf("unknown card %q", strings.Fields(rest)[0])
+		}
+		cards = append(cards, card)
+		rest = strings.TrimSpace(rest[n:])
+	}
+	return cards, nil
+}
+
+// parseCard reads the card at the start of text and returns it with the
+// length of its notation, which is zero if there is no card.
+func parseCard(text string) (Card, int) {
+	switch {
+	case strings.HasPrefix(text, "joker"):
+		return Card{BLACK_JOKER, NONE}, len("joker")
+	case strings.HasPrefix(text, "JOKER"):
+		return Card{RED_JOKER, NONE}, len("JOKER")
+	case hasPrefixFold(text, "bj"):
+		return Card{BLACK_JOKER, NONE}, len("bj")
+	case hasPrefixFold(text, "rj"):
+		return Card{RED_JOKER, NONE}, len("rj")
+	}
+
+	color, n := NONE, 0
+	for c := SPADE; c < NONE; c++ {
+		if strings.HasPrefix(text, suitSymbols[c]) {
+			color, n = c, len(suitSymbols[c])
+		} else if hasPrefixFold(text, suitLetters[c]) {
+			color, n = c, len(suitLetters[c])
+		}
+	}
+	// as in "♠ 3", which is how older versions showed the cards
+	if n > 0 {
+		n = len(text) - len(strings.TrimLeft(text[n:], " "))
+	}
+
+	if hasPrefixFold(text[n:], "T") {
+		return Card{TEN, color}, n + 1
+	}
+	for p := THREE; p < BLACK_JOKER; p++ {
+		if hasPrefixFold(text[n:], pointNames[p]) {
+			return Card{p, color}, n + len(pointNames[p])
+		}
+	}
+	return Card{}, 0
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// FormatCards writes the cards separated by spaces, which ParseCards reads
+// back.
+func FormatCards(cards []*Card) string {
+	names := make([]string, len(cards))
+	for i, c := range cards {
+		names[i] = c.String()
+	}
+	return strings.Join(names, " ")
+}
+
+func (c Card) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalJSON reads a card in the notation, or an object as older versions
+// saved the cards of the snapshots.
+func (c *Card) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte("{")) {
+		type card Card
+		return json.Unmarshal(data, (*card)(c))
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	cards, err := ParseCards(text)
+	if err != nil {
+		return err
+	}
+	if len(cards) != 1 {
+		return fmt.Errorf("expected a single card, got %q", text)
+	}
+	*c = cards[0]
+	return nil
+}
@@ -0,0 +1,116 @@
+package util
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseCards(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"33344", "3 3 3 4 4"},
+		{" 3 3  3 4 4 ", "3 3 3 4 4"},
+		{"TJQKA", "10 J Q K A"},
+		{"10jqka", "10 J Q K A"},
+		{"10 J Q K A", "10 J Q K A"},
+		{"♠3 ♥3", "♠3 ♥3"},
+		{"s3h3", "♠3 ♥3"},
+		{"S10 dA c2", "♠10 ♦A ♣2"},
+		{"♠ 3 ♥ 3", "♠3 ♥3"},
+		{"joker JOKER", "joker JOKER"},
+		{"bj RJ", "joker JOKER"},
+		{"bjrj22", "joker JOKER 2 2"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		cards, err := ParseCards(test.text)
+		if err != nil {
+			t.Errorf("unable to parse %q: %v", test.text, err)
+			continue
+		}
+		if formatted := FormatCards(pointers(cards)); formatted != test.expected {
+			t.Errorf("expected %q to be %q, got %q", test.text, test.expected, formatted)
+		}
+	}
+
+	for _, text := range []string{"X", "3 1", "33x", "♠", "♠joker", "Joker", "11"} {
+		if cards, err := ParseCards(text); err == nil {
+			t.Errorf("expected %q to be refused, got %v", text, cards)
+		}
+	}
+	if _, err := ParseCards("3 4X 5"); err == nil || err.Error() != `unknown card "X"` {
+		t.Errorf("expected the unknown card to be named, got %v", err)
+	}
+}
+
+func pointers(cards []Card) []*Card {
+	var ptrs []*Card
+	for i := range cards {
+		ptrs = append(ptrs, &cards[i])
+	}
+	return ptrs
+}
+
+func TestFormatCards(t *testing.T) {
+	deck := NewDeck()
+	cards := pointers(deck.Cards[:])
+	parsed, err := ParseCards(FormatCards(cards))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != NUM_CARDS {
+		t.Fatalf("expected %d cards, got %d", NUM_CARDS, len(parsed))
+	}
+	for i, card := range parsed {
+		if card != *cards[i] {
+			t.Errorf("expected %v, got %v", *cards[i], card)
+		}
+	}
+}
+
+func TestCardJSON(t *testing.T) {
+	cards := []Card{{THREE, HEART}, {TEN, SPADE}, {RED_JOKER, NONE}}
+	byts, err := json.Marshal(cards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(byts) != `["♥3","♠10","JOKER"]` {
+		t.Errorf("unexpected notation %s", byts)
+	}
+	var decoded []Card
+	if err := json.Unmarshal(byts, &decoded); err != nil || len(decoded) != 3 || decoded[1] != cards[1] {
+		t.Errorf("expected %v, got %v %v", cards, decoded, err)
+	}
+
+	// as saved by older versions
+	var old []Card
+	if err := json.Unmarshal([]byte(`[{"Point":1,"Color":2},{"Point":14,"Color":4}]`), &old); err != nil {
+		t.Fatal(err)
+	}
+	if old[0] != (Card{FOUR, HEART}) || old[1] != (Card{RED_JOKER, NONE}) {
+		t.Errorf("unexpected cards %v", old)
+	}
+}
+
+func FuzzParseCards(f *testing.F) {
+	for _, seed := range []string{"33344", "TJQKA", "s3 h3", "♠3♥3", "bj rj", "joker JOKER", "♠ 10"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, text string) {
+		cards, err := ParseCards(text)
+		if err != nil {
+			return
+		}
+		again, err := ParseCards(FormatCards(pointers(cards)))
+		if err != nil || len(again) != len(cards) {
+			t.Fatalf("%q is read back as %v %v", FormatCards(pointers(cards)), again, err)
+		}
+		for i := range cards {
+			if again[i] != cards[i] {
+				t.Fatalf("%v is read back as %v", cards, again)
+			}
+		}
+	})
+}
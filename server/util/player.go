@@ -2,7 +2,6 @@ package util
 
 import (
 	"errors"
-	"net"
 	"strings"
 
 	"golang.org/x/exp/slices"
@@ -15,21 +14,33 @@ const (
 	FARMER
 )
 
+var (
+	ErrInvalidCards = errors.New("> invalid cards")
+	ErrNotOwned     = errors.New("> you don't have the cards")
+	ErrCannotBeat   = errors.New("> cards can't beat last played cards")
+)
+
+// PlayerID identifies a player, independent of how the player is connected
+type PlayerID string
+
 type Player struct {
-	Cards    []*Card
-	Conn     net.Conn
-	Nick     string
+	Cards []*Card
+	ID    PlayerID
+	Nick  string
+	// Seat is the place of the player at the table, from 0 to NumPlayers-1
+	Seat     int
 	Position playerPosition
 	IsReady  bool
+	Plays    int
+	Bombs    int
 }
 
-func NewPlayer(conn net.Conn, nick string) *Player {
+func NewPlayer(id PlayerID, nick string) *Player {
 	return &Player{
-		[]*Card{},
-		conn,
-		nick,
-		FARMER,
-		false,
+		Cards:    []*Card{},
+		ID:       id,
+		Nick:     nick,
+		Position: FARMER,
 	}
 }
 
@@ -44,35 +55,35 @@ func (p *Player) Deal(d *Deck, n int) (err error) {
 	return
 }
 
+// Use plays cardsInfo from the hand of the player if they beat
+// lastCardsInfo. The cards of cardsInfo are replaced by the very cards taken
+// from the hand.
 func (p *Player) Use(cardsInfo []*Card, lastCardsInfo []*Card) error {
 	Sort(cardsInfo)
 	Sort(lastCardsInfo)
 	if !Valid(cardsInfo) {
-		return errors.New("> invalid cards")
+		return ErrInvalidCards
 	}
 
-	if !Contains(p.Cards, cardsInfo) {
-		return errors.New("> you don't have the cards")
+	matched := match(p.Cards, cardsInfo)
+	if matched == nil {
+		return ErrNotOwned
 	}
 
 	if !CompareTo(cardsInfo, lastCardsInfo) {
-		return errors.New("> cards can't beat last played cards")
+		return ErrCannotBeat
 	}
 
-	var removedCardsIdx []int
-
-	for _, cardInfo := range cardsInfo {
-		for i, card := range p.Cards {
-			if !slices.Contains(removedCardsIdx, i) && cardInfo.Equal(*card) {
-				removedCardsIdx = append(removedCardsIdx, i)
-				cardInfo.Color = card.Color
-				break
-			}
-		}
+	for i, j := range matched {
+		cardsInfo[i] = p.Cards[j]
 	}
-	slices.Sort(removedCardsIdx)
-	for i := len(removedCardsIdx) - 1; i >= 0; i-- {
-		p.Cards = append(p.Cards[:removedCardsIdx[i]], p.Cards[removedCardsIdx[i]+1:]...)
+	slices.Sort(matched)
+	for i := len(matched) - 1; i >= 0; i-- {
+		p.Cards = append(p.Cards[:matched[i]], p.Cards[matched[i]+1:]...)
+	}
+	p.Plays++
+	if isBomb(cardsInfo) {
+		p.Bombs++
 	}
 
 	p.Sort()
@@ -98,7 +109,7 @@ func (p *Player) Recommend(lastCards []*Card) []*Card {
 	}
 	for i := len(p.Cards) - 1; i >= 4-1; i-- {
 		cards := p.Cards[i-4+1 : i+1]
-		if isBomb(cards) {
+		if isBomb(cards) && CompareTo(cards, lastCards) {
 			return cards
 		}
 	}
@@ -151,12 +162,7 @@ func (p *Player) Sort() {
 }
 
 func (p *Player) String() string {
-	var cards []string
-	for i := 0; i < len(p.Cards); i++ {
-		cards = append(cards, p.Cards[i].String())
-	}
-
-	return "[" + strings.Join(cards, " ") + "]"
+	return "[" + FormatCards(p.Cards) + "]"
 }
 
 func (p *Player) Highlight(lastCards []*Card) string {
@@ -6,8 +6,54 @@ func TestPlayer(t *testing.T) {
 	deck := NewDeck()
 	t.Log(deck.String())
 	deck.Shuffle()
-	// p1 := NewPlayer("yff")
-	// p1.Deal(&deck, 17)
-	// p1.Sort()
-	// t.Log(p1.String())
+	p1 := NewPlayer("1", "yff")
+	if err := p1.Deal(&deck, 17); err != nil {
+		t.Fatal(err)
+	}
+	if len(p1.Cards) != 17 {
+		t.Errorf("expected 17 cards dealt, got %d", len(p1.Cards))
+	}
+	t.Log(p1.String())
+}
+
+func TestUseSuits(t *testing.T) {
+	p := NewPlayer("1", "alice")
+	p.Cards = []*Card{{THREE, SPADE}, {THREE, HEART}, {FOUR, CLUBS}}
+	cards, _ := ParseCards("♥3")
+	if err := p.Use(pointers(cards), nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Cards) != 2 || p.Cards[1].Color != SPADE {
+		t.Errorf("expected the ♥3 to be played, left %v", p)
+	}
+
+	p.Cards = []*Card{{THREE, SPADE}, {THREE, HEART}}
+	cards, _ = ParseCards("3 ♠3")
+	if err := p.Use(pointers(cards), nil); err != nil {
+		t.Fatalf("expected the suit given to be matched first: %v", err)
+	}
+	p.Cards = []*Card{{FOUR, CLUBS}}
+	cards, _ = ParseCards("♦4")
+	if err := p.Use(pointers(cards), nil); err != ErrNotOwned {
+		t.Errorf("expected the ♦4 not to be owned, got %v", err)
+	}
+}
+
+func TestRecommendBomb(t *testing.T) {
+	last, _ := ParseCards("♠K ♣K ♥K ♦K")
+	for _, c := range []struct {
+		hand, expected string
+	}{
+		{"♠7 ♣7 ♥7 ♦7 ♠3", ""},
+		{"♠7 ♣7 ♥7 ♦7 ♠A ♣A ♥A ♦A", "♠A ♣A ♥A ♦A"},
+		{"♠7 ♣7 ♥7 ♦7 joker JOKER", "JOKER joker"},
+	} {
+		p := NewPlayer("1", "alice")
+		cards, _ := ParseCards(c.hand)
+		p.Cards = pointers(cards)
+		p.Sort()
+		if recommend := FormatCards(p.Recommend(pointers(last))); recommend != c.expected {
+			t.Errorf("%s on %s: expected %q to be recommended, got %q", c.hand, FormatCards(pointers(last)), c.expected, recommend)
+		}
+	}
 }
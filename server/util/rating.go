@@ -0,0 +1,29 @@
+package util
+
+import "math"
+
+const (
+	INITIAL_RATING = 1500
+	RATING_K       = 32
+)
+
+// RatingChange returns how much the rating of the landlord changes after a
+// game. The farmers play as a team against the landlord: the team is rated
+// with the average rating of the farmers, who share the negated amount.
+func RatingChange(landlord float64, farmers []float64, landlordWon bool) float64 {
+	if len(farmers) == 0 {
+		return 0
+	}
+	team := 0.0
+	for _, rating := range farmers {
+		team += rating
+	}
+	team /= float64(len(farmers))
+
+	expected := 1 / (1 + math.Pow(10, (team-landlord)/400))
+	actual := 0.0
+	if landlordWon {
+		actual = 1
+	}
+	return RATING_K * (actual - expected)
+}
@@ -0,0 +1,28 @@
+package util
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRatingChange(t *testing.T) {
+	even := []float64{1500, 1500}
+	if d := RatingChange(1500, even, true); d != RATING_K/2 {
+		t.Errorf("even game won by the landlord: got %v, want %v", d, RATING_K/2)
+	}
+	if d := RatingChange(1500, even, false); d != -RATING_K/2 {
+		t.Errorf("even game lost by the landlord: got %v, want %v", d, -RATING_K/2)
+	}
+
+	strong := RatingChange(1700, even, true)
+	weak := RatingChange(1300, even, true)
+	if strong >= weak {
+		t.Errorf("beating weaker farmers should gain less: %v >= %v", strong, weak)
+	}
+	if d := RatingChange(1500, []float64{1300, 1700}, true); math.Abs(d-RATING_K/2) > 1e-9 {
+		t.Errorf("farmers should be rated by their average: got %v", d)
+	}
+	if d := RatingChange(1500, nil, true); d != 0 {
+		t.Errorf("a game without farmers should not change ratings: got %v", d)
+	}
+}
@@ -0,0 +1,71 @@
+package util
+
+import "time"
+
+// Result is the outcome of a finished game. Scores are zero-sum: the
+// landlord wins or loses the multiplier from every farmer.
+type Result struct {
+	Landlord    string         `json:"landlord"`
+	Winner      string         `json:"winner"`
+	LandlordWon bool           `json:"landlord_won"`
+	Spring      bool           `json:"spring"`
+	Bid         int            `json:"bid,omitempty"`
+	Multiplier  int            `json:"multiplier"`
+	Scores      map[string]int `json:"scores"`
+	Bombs       map[string]int `json:"bombs"`
+	StartedAt   time.Time      `json:"started_at"`
+	Duration    time.Duration  `json:"duration"`
+}
+
+// Result scores the game won by winner. The multiplier starts at the bid of
+// the landlord, or one without bidding, and doubles for every bomb or rocket
+// and for a spring, which is the landlord winning before any farmer played,
+// or the farmers winning after the landlord played only once.
+func (g *Game) Result(winner *Player) Result {
+	result := Result{
+		Winner:      winner.Nick,
+		LandlordWon: winner.Position == LANDLORD,
+		Bid:         g.Bid,
+		Multiplier:  max(g.Bid, 1),
+		Scores:      make(map[string]int),
+		Bombs:       make(map[string]int),
+		StartedAt:   g.StartedAt,
+		Duration:    time.Since(g.StartedAt),
+	}
+	farmerPlays := 0
+	for _, player := range g.Order {
+		result.Bombs[player.Nick] = player.Bombs
+		for i := 0; i < player.Bombs; i++ {
+			result.Multiplier *= 2
+		}
+		if player.Position == LANDLORD {
+			result.Landlord = player.Nick
+		} else {
+			farmerPlays += player.Plays
+		}
+	}
+	if g.Landlord != nil {
+		result.Spring = (result.LandlordWon && farmerPlays == 0) ||
+			(!result.LandlordWon && g.Landlord.Plays == 1)
+	}
+	if result.Spring {
+		result.Multiplier *= 2
+	}
+
+	for _, player := range g.Order {
+		if player.Position == LANDLORD {
+			continue
+		}
+		if result.LandlordWon {
+			result.Scores[player.Nick] = -result.Multiplier
+			result.Scores[result.Landlord] += result.Multiplier
+		} else {
+			result.Scores[player.Nick] = result.Multiplier
+			result.Scores[result.Landlord] -= result.Multiplier
+		}
+	}
+	if _, ok := result.Scores[result.Landlord]; !ok {
+		result.Scores[result.Landlord] = 0
+	}
+	return result
+}
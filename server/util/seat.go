@@ -0,0 +1,58 @@
+package util
+
+import "sort"
+
+// The turns go around the table by seat. The player who acts next sits on
+// the right, the player who acted before on the left.
+
+// Seated returns the players by seat.
+func (g *Game) Seated() []*Player {
+	var players []*Player
+	g.Players.Range(func(_, player any) bool {
+		players = append(players, player.(*Player))
+		return true
+	})
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].Seat < players[j].Seat
+	})
+	return players
+}
+
+// TakeSeats fixes the order of the game in progress to players, who take
+// the seats in that order.
+func (g *Game) TakeSeats(players []*Player) {
+	for i, player := range players {
+		player.Seat = i
+	}
+	g.Order = players
+}
+
+// Next is the player whose turn follows the turn of p.
+func (g *Game) Next(p *Player) *Player {
+	return g.Right(p)
+}
+
+// Right is the player sitting on the right of p.
+func (g *Game) Right(p *Player) *Player {
+	return g.Order[(p.Seat+1)%len(g.Order)]
+}
+
+// Left is the player sitting on the left of p.
+func (g *Game) Left(p *Player) *Player {
+	return g.Order[(p.Seat+len(g.Order)-1)%len(g.Order)]
+}
+
+// freeSeat is the lowest seat nobody sits on, or -1 if the table is full.
+func (g *Game) freeSeat() int {
+	taken := make(map[int]bool)
+	g.Players.Range(func(_, player any) bool {
+		taken[player.(*Player).Seat] = true
+		return true
+	})
+	for seat := 0; seat < g.NumPlayers; seat++ {
+		if !taken[seat] {
+			return seat
+		}
+	}
+	return -1
+}
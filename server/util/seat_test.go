@@ -0,0 +1,53 @@
+package util
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestSeats(t *testing.T) {
+	id := func(i int) PlayerID { return PlayerID(strconv.Itoa(i)) }
+	g := NewGame()
+	nicks := []string{"a", "b", "c"}
+	for i, nick := range nicks {
+		player, err := g.AddPlayer(id(i), nick)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if player.Seat != i {
+			t.Errorf("expected %s at seat %d, got %d", nick, i, player.Seat)
+		}
+	}
+	if again, _ := g.AddPlayer(id(1), "b"); again.Seat != 1 || g.PlayerNum != 3 {
+		t.Error("expected a seated player to keep the seat")
+	}
+	if _, err := g.AddPlayer(id(3), "d"); err != ErrTableFull {
+		t.Errorf("expected the table to be full, got %v", err)
+	}
+
+	g.RemovePlayer(id(1))
+	player, err := g.AddPlayer(id(3), "d")
+	if err != nil || player.Seat != 1 {
+		t.Fatalf("expected d to take the free seat 1: %v", err)
+	}
+	seated := g.Seated()
+	for i, nick := range []string{"a", "d", "c"} {
+		if seated[i].Nick != nick {
+			t.Errorf("expected %s at seat %d, got %s", nick, i, seated[i].Nick)
+		}
+	}
+
+	g.TakeSeats(seated)
+	a, d, c := seated[0], seated[1], seated[2]
+	if g.Next(a) != d || g.Next(d) != c || g.Next(c) != a {
+		t.Error("expected the turns to go around the table by seat")
+	}
+	if g.Right(a) != d || g.Left(a) != c || g.Left(d) != a {
+		t.Error("unexpected neighbours")
+	}
+
+	g.TakeSeats([]*Player{c, a, d})
+	if c.Seat != 0 || g.Next(c) != a || g.Next(d) != c {
+		t.Error("expected a new seat order to change the turns")
+	}
+}
@@ -0,0 +1,205 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type SeatSnapshot struct {
+	Nick     string `json:"nick"`
+	Cards    []Card `json:"cards"`
+	Landlord bool   `json:"landlord"`
+	Plays    int    `json:"plays"`
+	Bombs    int    `json:"bombs"`
+}
+
+// Snapshot is the serializable state of a game in progress. Seats are listed
+// in turn order.
+type Snapshot struct {
+	NumPlayers    int            `json:"num_players"`
+	Seats         []SeatSnapshot `json:"seats"`
+	Current       int            `json:"current"`
+	LastPlayer    int            `json:"last_player"`
+	LastUsedCards []Card         `json:"last_used_cards"`
+	Played        []Card         `json:"played,omitempty"`
+	StartedAt     time.Time      `json:"started_at"`
+	Plays         int            `json:"plays"`
+	Bid           int            `json:"bid,omitempty"`
+}
+
+func (snap *Snapshot) HasSeat(nick string) bool {
+	for _, seat := range snap.Seats {
+		if seat.Nick == nick {
+			return true
+		}
+	}
+	return false
+}
+
+// Check reports what makes snap impossible to continue, as the seats of the
+// current and the last player, or cards that aren't of the deck, that are in
+// two places or missing, which a corrupt or edited file may hold. The game
+// is saved once the landlord took the bottom cards, so every card of the
+// deck is in a hand or was played.
+func (snap *Snapshot) Check() error {
+	if len(snap.Seats) != snap.NumPlayers || snap.NumPlayers == 0 {
+		return errors.New("seats don't match the number of players")
+	}
+	if snap.Current < 0 || snap.Current >= len(snap.Seats) {
+		return fmt.Errorf("no seat for the current player %d", snap.Current)
+	}
+	if snap.LastPlayer < -1 || snap.LastPlayer >= len(snap.Seats) {
+		return fmt.Errorf("no seat for the last player %d", snap.LastPlayer)
+	}
+	if snap.Bid < 0 || snap.Bid > MAX_BID {
+		return fmt.Errorf("invalid bid %d", snap.Bid)
+	}
+
+	var places [NUM_CARDS]string
+	check := func(place string, cards []Card) error {
+		for _, c := range cards {
+			if !c.valid() {
+				return fmt.Errorf("invalid card %d of color %d in %s", c.Point, c.Color, place)
+			}
+			if places[c.ID()] != "" {
+				return fmt.Errorf("%v is both in %s and in %s", c, places[c.ID()], place)
+			}
+			places[c.ID()] = place
+		}
+		return nil
+	}
+	landlords := 0
+	nicks := make(map[string]bool)
+	for _, seat := range snap.Seats {
+		if nicks[seat.Nick] {
+			return fmt.Errorf("%s has two seats", seat.Nick)
+		}
+		nicks[seat.Nick] = true
+		if seat.Landlord {
+			landlords++
+		}
+		if err := check("the hand of "+seat.Nick, seat.Cards); err != nil {
+			return err
+		}
+	}
+	if landlords != 1 {
+		return fmt.Errorf("%d landlords", landlords)
+	}
+	if err := check("the cards played", snap.Played); err != nil {
+		return err
+	}
+	for id, place := range places {
+		if place == "" {
+			return fmt.Errorf("%v is missing", NewDeck().Cards[id])
+		}
+	}
+	for _, c := range snap.LastUsedCards {
+		if !c.valid() || places[c.ID()] != "the cards played" {
+			return fmt.Errorf("%v of the last cards played wasn't played", c)
+		}
+	}
+	return nil
+}
+
+// valid reports whether c is a card of the deck, a joker without suit or a
+// card of a suit.
+func (c Card) valid() bool {
+	if c.Point == BLACK_JOKER || c.Point == RED_JOKER {
+		return c.Color == NONE
+	}
+	return c.Point >= THREE && c.Point < BLACK_JOKER && c.Color >= SPADE && c.Color < NONE
+}
+
+func (g *Game) Snapshot() *Snapshot {
+	snap := &Snapshot{
+		NumPlayers: g.NumPlayers,
+		LastPlayer: -1,
+		StartedAt:  g.StartedAt,
+		Plays:      g.Plays,
+		Bid:        g.Bid,
+	}
+	for i, player := range g.Order {
+		seat := SeatSnapshot{
+			Nick:     player.Nick,
+			Cards:    copyCards(player.Cards),
+			Landlord: player.Position == LANDLORD,
+			Plays:    player.Plays,
+			Bombs:    player.Bombs,
+		}
+		snap.Seats = append(snap.Seats, seat)
+		if player == g.CurrentPlayer {
+			snap.Current = i
+		}
+		if player == g.LastPlayer {
+			snap.LastPlayer = i
+		}
+	}
+	snap.LastUsedCards = copyCards(g.LastUsedCards)
+	snap.Played = copyCards(g.Played)
+	return snap
+}
+
+// Restore continues the game of snap with the players that have taken their
+// seats again. It returns the seat of the current player.
+func (g *Game) Restore(snap *Snapshot) (current int, err error) {
+	if err := snap.Check(); err != nil {
+		return 0, err
+	}
+	players := make(map[string]*Player)
+	g.Players.Range(func(_, player any) bool {
+		players[player.(*Player).Nick] = player.(*Player)
+		return true
+	})
+
+	var order []*Player
+	for _, seat := range snap.Seats {
+		player, ok := players[seat.Nick]
+		if !ok {
+			return 0, fmt.Errorf("%s has not taken their seat", seat.Nick)
+		}
+		player.Cards = nil
+		for i := range seat.Cards {
+			player.Cards = append(player.Cards, &seat.Cards[i])
+		}
+		player.Sort()
+		player.Plays = seat.Plays
+		player.Bombs = seat.Bombs
+		player.Position = FARMER
+		if seat.Landlord {
+			player.Position = LANDLORD
+			g.Landlord = player
+		}
+		order = append(order, player)
+	}
+	g.TakeSeats(order)
+
+	g.NumPlayers = snap.NumPlayers
+	g.CurrentPlayer = g.Order[snap.Current]
+	g.LastPlayer = nil
+	if snap.LastPlayer >= 0 {
+		g.LastPlayer = g.Order[snap.LastPlayer]
+	}
+	g.LastUsedCards = nil
+	for i := range snap.LastUsedCards {
+		g.LastUsedCards = append(g.LastUsedCards, &snap.LastUsedCards[i])
+	}
+	g.Played = nil
+	for i := range snap.Played {
+		g.Played = append(g.Played, &snap.Played[i])
+	}
+	// every card has been dealt
+	g.Deck = Deck{current: -1}
+	g.StartedAt = snap.StartedAt
+	g.Plays = snap.Plays
+	g.Bid = snap.Bid
+	return snap.Current, nil
+}
+
+func copyCards(cards []*Card) []Card {
+	copied := make([]Card, len(cards))
+	for i, c := range cards {
+		copied[i] = *c
+	}
+	return copied
+}
@@ -0,0 +1,52 @@
+package util
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSnapshotCheck(t *testing.T) {
+	g, players, _ := newEngineGame(t, false)
+	byts, err := json.Marshal(g.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := map[string]func(*Snapshot){
+		"valid":                 func(*Snapshot) {},
+		"current player":        func(snap *Snapshot) { snap.Current = 3 },
+		"last player":           func(snap *Snapshot) { snap.LastPlayer = -2 },
+		"missing seat":          func(snap *Snapshot) { snap.Seats = snap.Seats[1:] },
+		"two landlords":         func(snap *Snapshot) { snap.Seats[1].Landlord = true },
+		"no landlord":           func(snap *Snapshot) { snap.Seats[0].Landlord = false },
+		"two seats":             func(snap *Snapshot) { snap.Seats[1].Nick = snap.Seats[0].Nick },
+		"point":                 func(snap *Snapshot) { snap.Seats[0].Cards[0].Point = 99 },
+		"color":                 func(snap *Snapshot) { snap.Seats[0].Cards[0].Color = -1 },
+		"card without suit":     func(snap *Snapshot) { snap.Seats[0].Cards[0] = Card{THREE, NONE} },
+		"card twice":            func(snap *Snapshot) { snap.Played = append(snap.Played, snap.Seats[1].Cards[0]) },
+		"last cards":            func(snap *Snapshot) { snap.LastUsedCards = []Card{{RED_JOKER, SPADE}} },
+		"last cards not played": func(snap *Snapshot) { snap.LastUsedCards = snap.Seats[1].Cards[:1] },
+		"missing card":          func(snap *Snapshot) { snap.Seats[2].Cards = snap.Seats[2].Cards[1:] },
+		"bid":                   func(snap *Snapshot) { snap.Bid = MAX_BID + 1 },
+	}
+	for name, f := range corrupt {
+		var snap Snapshot
+		if err := json.Unmarshal(byts, &snap); err != nil {
+			t.Fatal(err)
+		}
+		f(&snap)
+
+		restored := NewGame()
+		for _, player := range players {
+			restored.AddPlayer(player.ID, player.Nick)
+		}
+		_, err := restored.Restore(&snap)
+		if name == "valid" {
+			if err != nil {
+				t.Errorf("restoring a valid snapshot: %v", err)
+			}
+		} else if err == nil {
+			t.Errorf("%s: the corrupt snapshot was restored", name)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"embed"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+//go:embed web
+var webFiles embed.FS
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// WebHandler serves the embedded browser client and the websocket endpoint
+// it talks to.
+func (s *server) WebHandler() http.Handler {
+	static, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		log.Fatalf("unable to load web files: %s", err.Error())
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.FS(static)))
+	mux.HandleFunc("/ws", s.serveWs)
+	return mux
+}
+
+func (s *server) serveWs(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("unable to upgrade connection: %s", err.Error())
+		return
+	}
+	conn := &wsConn{Conn: ws}
+	conn.SetDeadline(time.Now().Add(s.idleTimeout))
+	log.Printf("web client has connected: %s", conn.RemoteAddr().String())
+	s.NewClient(conn)
+}
+
+// wsConn adapts a websocket to the line based protocol spoken over tcp, so
+// that browser clients can be handled exactly like terminal clients. Every
+// incoming text message is read as one line and every write is sent as one
+// text message.
+type wsConn struct {
+	*websocket.Conn
+	reader io.Reader
+	mu     sync.Mutex
+	closed bool
+}
+
+func (c *wsConn) Read(p []byte) (int, error) {
+	for {
+		if c.reader == nil {
+			_, r, err := c.NextReader()
+			if err != nil {
+				c.Close()
+				return 0, err
+			}
+			c.reader = io.MultiReader(r, strings.NewReader("\n"))
+		}
+		n, err := c.reader.Read(p)
+		if err == io.EOF {
+			c.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (c *wsConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := c.WriteMessage(websocket.TextMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *wsConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.Conn.Close()
+}
+
+func (c *wsConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
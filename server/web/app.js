@@ -0,0 +1,232 @@
+"use strict";
+
+// message types, kept in sync with server/client.go
+const MSG_MESSAGE = 0;
+const MSG_ERROR = 1;
+const MSG_PLAYER_STATUS = 2;
+const MSG_INFO = 3;
+const MSG_CHAT = 4;
+const MSG_ROOM_INFO = 5;
+const MSG_STOP = 6;
+const MSG_STATS = 7;
+
+const CARD_PATTERN = /([♠♣♥♦]?)\s?(10|[2-9JQKA]|joker|JOKER)/g;
+
+const $ = (id) => document.getElementById(id);
+
+let ws = null;
+let loggedIn = false;
+let hand = [];
+let selected = new Set();
+
+function connect(handshake) {
+  const scheme = location.protocol === "https:" ? "wss" : "ws";
+  ws = new WebSocket(`${scheme}://${location.host}/ws`);
+  ws.onopen = () => ws.send(handshake);
+  ws.onmessage = (event) => {
+    for (const line of event.data.split("\n")) {
+      if (line.length > 0) {
+        receive(line);
+      }
+    }
+  };
+  ws.onclose = () => {
+    append($("messages"), "> disconnected from the server");
+    disableActions();
+  };
+}
+
+function send(text) {
+  if (ws && ws.readyState === WebSocket.OPEN) {
+    ws.send(text);
+  }
+}
+
+function receive(line) {
+  if (!loggedIn) {
+    if (line === "ok") {
+      loggedIn = true;
+      $("login").hidden = true;
+      $("game").hidden = false;
+    } else {
+      $("login-error").textContent = line;
+    }
+    return;
+  }
+
+  const msg = JSON.parse(line);
+  switch (msg.msg_type) {
+    case MSG_MESSAGE:
+      append($("messages"), msg.content);
+      watchTrick(msg.content, msg.sender);
+      watchRoom(msg.content);
+      break;
+    case MSG_ERROR:
+    case MSG_INFO:
+      append($("info"), msg.content);
+      break;
+    case MSG_PLAYER_STATUS:
+      renderHand(msg.content);
+      break;
+    case MSG_CHAT:
+      append($("chat"), msg.content);
+      break;
+    case MSG_ROOM_INFO:
+      renderRoom(msg.content);
+      break;
+    case MSG_STATS:
+      $("leaderboard").textContent = msg.content;
+      break;
+    case MSG_STOP:
+      append($("messages"), msg.content);
+      disableActions();
+      ws.close();
+      break;
+  }
+}
+
+function append(log, text) {
+  log.textContent += text + "\n";
+  log.scrollTop = log.scrollHeight;
+}
+
+function parseCards(text) {
+  return [...text.matchAll(CARD_PATTERN)].map((m) => ({ suit: m[1], point: m[2] }));
+}
+
+function cardElement(card) {
+  const el = document.createElement("div");
+  el.className = "card";
+  if (card.suit === "♥" || card.suit === "♦" || card.point === "JOKER") {
+    el.classList.add("red");
+  }
+  el.textContent = card.point === "joker" || card.point === "JOKER" ? card.point : card.suit + card.point;
+  return el;
+}
+
+function renderRoom(content) {
+  const idx = content.indexOf("_");
+  const state = idx < 0 ? content : content.slice(0, idx);
+  const players = idx < 0 ? "" : content.slice(idx + 1);
+  $("room-state").textContent = state;
+  const list = $("room-players");
+  list.replaceChildren();
+  for (const player of players.split("\n")) {
+    if (player.trim().length === 0) {
+      continue;
+    }
+    const li = document.createElement("li");
+    li.textContent = player.replace(/^\s*-/, "").trim();
+    list.appendChild(li);
+  }
+  if (state !== "In game") {
+    hand = [];
+    selected.clear();
+    drawHand();
+  }
+}
+
+function renderHand(content) {
+  const idx = content.indexOf("_");
+  $("position").textContent = "(" + content.slice(0, idx) + ")";
+  hand = parseCards(content.slice(idx + 1));
+  selected.clear();
+  drawHand();
+}
+
+function drawHand() {
+  const container = $("hand");
+  container.replaceChildren();
+  hand.forEach((card, i) => {
+    const el = cardElement(card);
+    if (selected.has(i)) {
+      el.classList.add("selected");
+    }
+    el.onclick = () => {
+      if (selected.has(i)) {
+        selected.delete(i);
+      } else {
+        selected.add(i);
+      }
+      el.classList.toggle("selected");
+    };
+    container.appendChild(el);
+  });
+}
+
+// The server announces plays as chat-like messages, e.g.
+// "> alice used the cards: [♠ 3 ♥ 3]", so the last trick is
+// reconstructed from them.
+function watchTrick(content, nick) {
+  const used = content.match(/^> (.+?) used the cards: (\[.*?\])/);
+  if (used) {
+    const player = used[1] === "you" ? nick : used[1];
+    $("trick-player").textContent = player;
+    const cards = $("trick-cards");
+    cards.replaceChildren(...parseCards(used[2]).map(cardElement));
+    return;
+  }
+  if (/won the game|game ends/.test(content)) {
+    $("trick-player").textContent = "";
+    $("trick-cards").replaceChildren();
+  }
+}
+
+function watchRoom(content) {
+  const room = content.match(/^> you are in the room (\S+)/);
+  if (room) {
+    $("room-name").textContent = room[1];
+  }
+}
+
+function disableActions() {
+  for (const button of document.querySelectorAll("#actions button")) {
+    button.disabled = true;
+  }
+}
+
+// The first line sent is either a guest nickname or
+// "/login <nickname> <password>" or "/register <nickname> <password>".
+$("login").onsubmit = (event) => {
+  event.preventDefault();
+  const nick = $("nick").value.trim();
+  const password = $("password").value;
+  if (nick.length === 0) {
+    return;
+  }
+  const action = event.submitter ? event.submitter.value : "guest";
+  if (action !== "guest" && password.length === 0) {
+    $("login-error").textContent = "please enter your password";
+    return;
+  }
+  const handshake = action === "guest" ? nick : `/${action} ${nick} ${password}`;
+  $("login-error").textContent = "";
+  if (ws === null || ws.readyState > WebSocket.OPEN) {
+    connect(handshake);
+  } else {
+    send(handshake);
+  }
+};
+
+$("chat-form").onsubmit = (event) => {
+  event.preventDefault();
+  const text = $("chat-input").value.trim();
+  // plain lines are chat, but a leading "/" would be read as a command
+  if (text.length > 0 && text[0] !== "/") {
+    send(text);
+  }
+  $("chat-input").value = "";
+};
+
+$("ready").onclick = () => send("/ready");
+$("queue").onclick = () => send("/queue");
+$("pass").onclick = () => send("/pass");
+$("quit").onclick = () => send("/quit");
+$("play").onclick = () => {
+  if (selected.size === 0) {
+    append($("info"), "> select the cards you want to play first");
+    return;
+  }
+  const cards = [...selected].map((i) => hand[i].suit + hand[i].point);
+  send("/use " + cards.join(" "));
+};